@@ -36,17 +36,22 @@ import (
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/exporter-toolkit/web"
 	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
+	"github.com/prometheus/prometheus/model/relabel"
 
 	"github.com/tjhop/ns1_exporter/internal/version"
 	"github.com/tjhop/ns1_exporter/pkg/exporter"
 	"github.com/tjhop/ns1_exporter/pkg/metrics"
 	"github.com/tjhop/ns1_exporter/pkg/ns1"
 	sd "github.com/tjhop/ns1_exporter/pkg/servicediscovery"
+	"github.com/tjhop/ns1_exporter/pkg/storage"
 )
 
 const (
 	programName = "ns1_exporter"
 	defaultPort = 8080
+	// shutdownTimeout is how long the web server waits for active
+	// requests to finish when shutting down.
+	shutdownTimeout = 30 * time.Second
 )
 
 var (
@@ -78,6 +83,111 @@ var (
 		"NS1 API request concurrency. Default (0) uses NS1 Go SDK sleep strategry. 60 may be good balance between performance and reduced risk of HTTP 429, see https://pkg.go.dev/gopkg.in/ns1/ns1-go.v2/rest and exporter documentation for more information.",
 	).Default("0").Int()
 
+	flagNS1Endpoint = kingpin.Flag(
+		"ns1.endpoint",
+		"NS1 API endpoint to query, such as the address of a private NS1 deployment or of the ns1_simulator. Default (empty) uses the public NS1 API.",
+	).Default("").String()
+
+	flagNS1APIKeyFile = kingpin.Flag(
+		"ns1.api-key-file",
+		"Path to a file containing the NS1 API key. Takes precedence over the NS1_APIKEY environment variable. The file is watched and the key is swapped in without a restart when it changes.",
+	).Default("").String()
+
+	flagNS1APIKeyFileReloadInterval = kingpin.Flag(
+		"ns1.api-key-file-reload-interval",
+		"Interval at which the file set by --ns1.api-key-file is checked for a new API key.",
+	).Default("30s").Duration()
+
+	flagNS1TLSCAFile = kingpin.Flag(
+		"ns1.tls-ca-file",
+		"Path to a PEM encoded CA bundle used to verify the NS1 API's certificate. Default (empty) uses the system CA pool.",
+	).Default("").String()
+
+	flagNS1TLSCertFile = kingpin.Flag(
+		"ns1.tls-cert-file",
+		"Path to a PEM encoded client certificate to present to the NS1 API. Requires --ns1.tls-key-file.",
+	).Default("").String()
+
+	flagNS1TLSKeyFile = kingpin.Flag(
+		"ns1.tls-key-file",
+		"Path to the PEM encoded key for --ns1.tls-cert-file.",
+	).Default("").String()
+
+	flagNS1TLSInsecureSkipVerify = kingpin.Flag(
+		"ns1.tls-insecure-skip-verify",
+		"Whether or not to skip verification of the NS1 API's certificate. Not recommended outside of testing.",
+	).Default("false").Bool()
+
+	flagNS1ProxyURL = kingpin.Flag(
+		"ns1.proxy-url",
+		"HTTP proxy to use for requests to the NS1 API. Default (empty) uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.",
+	).Default("").String()
+
+	flagNS1HTTPTimeout = kingpin.Flag(
+		"ns1.http-timeout",
+		"Timeout for individual HTTP requests to the NS1 API.",
+	).Default(ns1.DefaultTimeout.String()).Duration()
+
+	flagNS1APICacheTTL = kingpin.Flag(
+		"ns1.api-cache-ttl",
+		"Duration for which zone and record lookups from the NS1 API are cached and shared between the exporter and service discovery. Default (0) disables caching.",
+	).Default("0s").Duration()
+
+	flagNS1APIRateLimit = kingpin.Flag(
+		"ns1.api-rate-limit",
+		"Maximum number of NS1 API requests per second, applied in addition to the NS1 Go SDK rate limiting strategy. Default (0) disables the limit.",
+	).Default("0").Float64()
+
+	flagNS1APIMaxRetries = kingpin.Flag(
+		"ns1.api-max-retries",
+		"Maximum number of times an NS1 API request that failed with a timeout, HTTP 429 or HTTP 5xx response is retried. Set to 0 to disable retries.",
+	).Default("3").Int()
+
+	flagNS1APIRetryInitialBackoff = kingpin.Flag(
+		"ns1.api-retry-initial-backoff",
+		"Upper bound of the randomized delay before the first retry of a failed NS1 API request. The bound doubles with each retry.",
+	).Default("1s").Duration()
+
+	flagNS1APIRetryMaxBackoff = kingpin.Flag(
+		"ns1.api-retry-max-backoff",
+		"Maximum upper bound of the randomized delay between retries of a failed NS1 API request.",
+	).Default("30s").Duration()
+
+	flagNS1APICircuitBreakerThreshold = kingpin.Flag(
+		"ns1.api-circuit-breaker-threshold",
+		"Number of consecutive failed NS1 API requests after which requests are stopped for --ns1.api-circuit-breaker-timeout. Set to 0 to disable the circuit breaker.",
+	).Default("5").Int()
+
+	flagNS1APICircuitBreakerTimeout = kingpin.Flag(
+		"ns1.api-circuit-breaker-timeout",
+		"Duration for which NS1 API requests are stopped once the circuit breaker opens, before a single trial request is made.",
+	).Default("1m").Duration()
+
+	flagNS1RecordDir = kingpin.Flag(
+		"ns1.record-dir",
+		"Directory in which to record sanitized NS1 API responses as fixtures for later use with --ns1.replay-dir.",
+	).Default("").String()
+
+	flagNS1ReplayDir = kingpin.Flag(
+		"ns1.replay-dir",
+		"Directory of fixtures recorded with --ns1.record-dir to serve instead of querying the NS1 API. No API key is required in replay mode.",
+	).Default("").String()
+
+	flagNS1EnableViews = kingpin.Flag(
+		"ns1.enable-views",
+		"Whether or not to look up the NS1 DNS views of each zone, which requires an additional NS1 API request per refresh. Views are exposed as the __meta_ns1_zone_views service discovery label and the views QPS label. Default is disabled.",
+	).Default("false").Bool()
+
+	flagNS1ViewWhitelistRegex = kingpin.Flag(
+		"ns1.view-whitelist",
+		"A regular expression of NS1 DNS view(s) that zones must be in at least one of to be queried by the exporter and service discovery mechanism. Requires --ns1.enable-views.",
+	).Default("").Regexp()
+
+	flagNS1NetworkWhitelist = kingpin.Flag(
+		"ns1.network-whitelist",
+		"The ID of an NS1 network that zones must be served on to be queried by the exporter and service discovery mechanism. Zones without networks are served on the global network (0). May be repeated.",
+	).Ints()
+
 	flagNS1ExporterEnableRecordQPS = kingpin.Flag(
 		"ns1.exporter-enable-record-qps",
 		"Whether or not to enable retrieving record-level QPS stats from the NS1 API. Default is enabled.",
@@ -98,6 +208,56 @@ var (
 		"A regular expression of zone(s) the exporter is allowed to query qps stats for.",
 	).Default("").Regexp()
 
+	flagNS1ExporterRecordDomainBlacklistRegex = kingpin.Flag(
+		"ns1.exporter-record-domain-blacklist",
+		"A regular expression of record domain(s) the exporter is not allowed to query record-level qps stats for.",
+	).Default("").Regexp()
+
+	flagNS1ExporterRecordDomainWhitelistRegex = kingpin.Flag(
+		"ns1.exporter-record-domain-whitelist",
+		"A regular expression of record domain(s) the exporter is allowed to query record-level qps stats for.",
+	).Default("").Regexp()
+
+	flagNS1ExporterRecordTypeRegex = kingpin.Flag(
+		"ns1.exporter-record-type",
+		"A regular expression of record types the exporter is allowed to query record-level qps stats for.",
+	).Default("").Regexp()
+
+	flagNS1ExporterRecordTags = kingpin.Flag(
+		"ns1.exporter-record-tag",
+		"A `key=regex` matcher that a record's tag must match for the exporter to query record-level qps stats for it. May be repeated, in which case all matchers must match.",
+	).Strings()
+
+	flagNS1ExporterRecordMeta = kingpin.Flag(
+		"ns1.exporter-record-meta",
+		"A `key=regex` matcher that a record's meta must match for the exporter to query record-level qps stats for it. May be repeated, in which case all matchers must match. Requires an additional NS1 API request per record the first time it's seen, and again after account activity that may have changed it, along with an account activity request per refresh.",
+	).Strings()
+
+	flagNS1ExporterTagLabels = kingpin.Flag(
+		"ns1.exporter-tag-label",
+		"An NS1 zone/record tag to add as a label to QPS metrics, given as `tag` or `tag=label` to use a different label name. Record tags take precedence over zone tags. May be repeated.",
+	).Strings()
+
+	flagNS1ExporterViewLabels = kingpin.Flag(
+		"ns1.exporter-view-labels",
+		"Whether or not to add the comma separated NS1 DNS views and network IDs of a zone to its QPS metrics as the `views` and `networks` labels. Views are empty unless --ns1.enable-views is set. Default is disabled.",
+	).Default("false").Bool()
+
+	flagNS1ExporterRelabelConfigFile = kingpin.Flag(
+		"ns1.exporter-relabel-config-file",
+		"Path to a YAML file with `metric_relabel_configs` to apply to QPS metrics before they are exposed, using Prometheus relabel config syntax. Relabeling is applied after tag labels are added.",
+	).Default("").String()
+
+	flagNS1ExporterRecordQPSTopN = kingpin.Flag(
+		"ns1.exporter-record-qps-top-n",
+		"Maximum number of records per zone to expose record-level QPS series for, keeping the records with the highest QPS. QPS of the remaining records is summed into a per-zone series with record_name=\"__other__\". Default (0) disables the limit.",
+	).Default("0").Int()
+
+	flagNS1ExporterRecordQPSMin = kingpin.Flag(
+		"ns1.exporter-record-qps-min",
+		"Minimum QPS of a record to expose a record-level QPS series for. QPS of records below the minimum is summed into a per-zone series with record_name=\"__other__\". Default (0) disables the limit.",
+	).Default("0").Float64()
+
 	flagNS1EnableSD = kingpin.Flag(
 		"ns1.enable-service-discovery",
 		"Whether or not to enable an HTTP endpoint to expose NS1 DNS records as HTTP service discovery targets. Default is disabled.",
@@ -123,6 +283,76 @@ var (
 		"A regular expression of record types that the service discovery mechanism will provide targets for.",
 	).Default("").Regexp()
 
+	flagNS1SDStructuredLabels = kingpin.Flag(
+		"ns1.sd-structured-labels",
+		"Whether or not to add an individual target label for each meta key, filter and answer of a record, such as __meta_ns1_record_meta_<key>, alongside the delimited string labels.",
+	).Default("false").Bool()
+
+	flagNS1SDTargetMode = kingpin.Flag(
+		"ns1.sd-target-mode",
+		"Which targets the service discovery mechanism creates for each record. One of 'record' (one target per record), 'answer' (one target per answer) or 'rdata' (one target per rdata field of each answer, such as each IP address).",
+	).Default(string(sd.TargetModeRecord)).Enum(string(sd.TargetModeRecord), string(sd.TargetModeAnswer), string(sd.TargetModeRdata))
+
+	flagNS1SDTargetAddressTemplate = kingpin.Flag(
+		"ns1.sd-target-address-template",
+		"A Go template for the address of each service discovery target, such as '{{.Rdata0}}:9100'. Default (empty) uses '<domain>-<type>' for record targets and '{{.Host}}' for answer and rdata targets.",
+	).Default("").String()
+
+	flagNS1SDLinkMode = kingpin.Flag(
+		"ns1.sd-link-mode",
+		"How the service discovery mechanism creates targets for linked records. One of 'include' (keep linked records as they are, without answers), 'resolve' (use the answers of the record at the end of the link chain) or 'suppress' (no targets for linked records).",
+	).Default(string(sd.LinkModeInclude)).Enum(string(sd.LinkModeInclude), string(sd.LinkModeResolve), string(sd.LinkModeSuppress))
+
+	flagNS1SDExpandAliases = kingpin.Flag(
+		"ns1.sd-expand-aliases",
+		"Whether or not to create the answer and rdata targets of CNAME and ALIAS records for the answers of the A and AAAA records at the end of their CNAME/ALIAS chains, followed across the records cached by the service discovery mechanism without querying DNS. Default is disabled.",
+	).Default("false").Bool()
+
+	flagNS1SDJobsConfigFile = kingpin.Flag(
+		"ns1.sd-jobs-config-file",
+		"Path to a YAML file with named service discovery jobs, each served at /sd/<name> with its own record filters and target options. Requires --ns1.enable-service-discovery.",
+	).Default("").String()
+
+	flagNS1SDFileSDDir = kingpin.Flag(
+		"ns1.sd-file-sd-dir",
+		"Directory in which to write the service discovery targets as Prometheus file_sd files whenever they change, to sd.<format> and to sd_<name>.<format> for each named job. Default (empty) disables file_sd. Requires --ns1.enable-service-discovery.",
+	).Default("").String()
+
+	flagNS1SDFileSDFormat = kingpin.Flag(
+		"ns1.sd-file-sd-format",
+		"Format of the file_sd files written to --ns1.sd-file-sd-dir. One of 'json' or 'yaml'.",
+	).Default(string(sd.FileSDFormatJSON)).Enum(string(sd.FileSDFormatJSON), string(sd.FileSDFormatYAML))
+
+	flagNS1SDEnableFilterSimulation = kingpin.Flag(
+		"ns1.sd-enable-filter-simulation",
+		"Whether or not to serve the /debug/ns1/simulate endpoint, which evaluates the filter chain of records cached by the service discovery mechanism locally for a given client. Requires --ns1.enable-service-discovery.",
+	).Default("false").Bool()
+
+	flagNS1SDEnableConsulAPI = kingpin.Flag(
+		"ns1.sd-enable-consul-api",
+		"Whether or not to serve a read-only subset of the Consul HTTP API under /v1/, with a service for each domain of the A and AAAA records cached by the service discovery mechanism, for tools that speak the Consul catalog API. Requires --ns1.enable-service-discovery.",
+	).Default("false").Bool()
+
+	flagNS1SDConsulDatacenter = kingpin.Flag(
+		"ns1.sd-consul-datacenter",
+		"Datacenter reported by the Consul API.",
+	).Default("ns1").String()
+
+	flagNS1SDConsulServicePort = kingpin.Flag(
+		"ns1.sd-consul-service-port",
+		"Port reported by the Consul API for all service instances.",
+	).Default("0").Int()
+
+	flagNS1SDEnableTagsInfo = kingpin.Flag(
+		"ns1.sd-enable-tags-info",
+		"Whether or not to expose the tags and meta of the zones and records cached by the service discovery mechanism as ns1_zone_tags_info and ns1_record_tags_info metrics. Requires --ns1.enable-service-discovery.",
+	).Default("false").Bool()
+
+	flagStoragePath = kingpin.Flag(
+		"storage.path",
+		"Directory in which to persist snapshots of zone, record, and QPS caches for warm restarts. Default (empty) disables storage.",
+	).Default("").String()
+
 	flagRuntimeGOMAXPROCS = kingpin.Flag(
 		"runtime.gomaxprocs", "The target number of CPUs Go will run on (GOMAXPROCS).",
 	).Envar("GOMAXPROCS").Default("1").Int()
@@ -154,19 +384,136 @@ func main() {
 }
 
 func Run(logger *slog.Logger) {
-	token := os.Getenv("NS1_APIKEY")
-	if token == "" {
-		logger.Error("NS1_APIKEY environment variable is not set")
+	backend, apiKeyFile := setupBackend(logger)
+	if *flagNS1APIRateLimit > 0 {
+		backend = ns1.NewRateLimitedBackend(backend, *flagNS1APIRateLimit)
+	}
+	if *flagNS1APIMaxRetries > 0 {
+		backend = ns1.NewRetryingBackend(backend, ns1.RetryConfig{
+			MaxRetries:     *flagNS1APIMaxRetries,
+			InitialBackoff: *flagNS1APIRetryInitialBackoff,
+			MaxBackoff:     *flagNS1APIRetryMaxBackoff,
+		})
+	}
+	if *flagNS1APICircuitBreakerThreshold > 0 {
+		backend = ns1.NewCircuitBreakerBackend(logger, backend, *flagNS1APICircuitBreakerThreshold, *flagNS1APICircuitBreakerTimeout)
+	}
+	if *flagNS1APICacheTTL > 0 {
+		backend = ns1.NewCachingBackend(backend, *flagNS1APICacheTTL)
+	}
+
+	tagLabels, err := exporter.ParseTagLabels(*flagNS1ExporterTagLabels)
+	if err != nil {
+		logger.Error("Failed to parse tag labels", "err", err)
+		os.Exit(1)
+	}
+
+	var relabelConfigs []*relabel.Config
+	if *flagNS1ExporterRelabelConfigFile != "" {
+		relabelConfigs, err = exporter.LoadRelabelConfigs(*flagNS1ExporterRelabelConfigFile)
+		if err != nil {
+			logger.Error("Failed to load relabel config file", "err", err, "path", *flagNS1ExporterRelabelConfigFile)
+			os.Exit(1)
+		}
+	}
+
+	if *flagNS1ViewWhitelistRegex != nil && (*flagNS1ViewWhitelistRegex).String() != "" && !*flagNS1EnableViews {
+		logger.Error("--ns1.view-whitelist requires --ns1.enable-views")
 		os.Exit(1)
 	}
+	views := ns1.ViewOptions{
+		Enabled:          *flagNS1EnableViews,
+		ViewWhitelist:    *flagNS1ViewWhitelistRegex,
+		NetworkWhitelist: *flagNS1NetworkWhitelist,
+	}
 
-	apiClient := ns1.NewClient(ns1.APIConfig{
-		Token:       token,
-		Concurrency: *flagNS1Concurrency,
-		UserAgent:   "ns1_exporter/" + version.Version,
+	exporterWorker := exporter.NewWorker(logger, backend, *flagNS1ExporterEnableZoneQPS, *flagNS1ExporterEnableRecordQPS, *flagNS1ExporterZoneBlacklistRegex, *flagNS1ExporterZoneWhitelistRegex, exporter.LabelConfig{
+		TagLabels:      tagLabels,
+		ViewLabels:     *flagNS1ExporterViewLabels,
+		RelabelConfigs: relabelConfigs,
 	})
-	exporterWorker := exporter.NewWorker(logger, apiClient, *flagNS1ExporterEnableZoneQPS, *flagNS1ExporterEnableRecordQPS, *flagNS1ExporterZoneBlacklistRegex, *flagNS1ExporterZoneWhitelistRegex)
-	sdWorker := sd.NewWorker(logger, apiClient, *flagNS1SDZoneBlacklistRegex, *flagNS1SDZoneWhitelistRegex, *flagNS1SDRecordTypeRegex)
+	exporterWorker.Views = views
+	exporterWorker.RecordQPSTopN = *flagNS1ExporterRecordQPSTopN
+	exporterWorker.RecordQPSMinimum = *flagNS1ExporterRecordQPSMin
+	exporterWorker.RecordFilter = setupRecordFilter(logger)
+	sdWorker := sd.NewWorker(logger, backend, *flagNS1SDZoneBlacklistRegex, *flagNS1SDZoneWhitelistRegex, *flagNS1SDRecordTypeRegex)
+	sdWorker.Views = views
+	sdWorker.TargetOptions.StructuredLabels = *flagNS1SDStructuredLabels
+	sdWorker.TargetOptions.ExpandAliases = *flagNS1SDExpandAliases
+	sdWorker.TargetOptions.Mode, err = sd.ParseTargetMode(*flagNS1SDTargetMode)
+	if err != nil {
+		logger.Error("Failed to parse service discovery target mode", "err", err)
+		os.Exit(1)
+	}
+	sdWorker.TargetOptions.AddressTemplate, err = sd.ParseAddressTemplate(*flagNS1SDTargetAddressTemplate)
+	if err != nil {
+		logger.Error("Failed to parse service discovery target address template", "err", err)
+		os.Exit(1)
+	}
+	sdWorker.TargetOptions.LinkMode, err = sd.ParseLinkMode(*flagNS1SDLinkMode)
+	if err != nil {
+		logger.Error("Failed to parse service discovery link mode", "err", err)
+		os.Exit(1)
+	}
+	if *flagNS1SDJobsConfigFile != "" {
+		if !*flagNS1EnableSD {
+			logger.Error("--ns1.sd-jobs-config-file requires --ns1.enable-service-discovery")
+			os.Exit(1)
+		}
+
+		sdWorker.Jobs, err = sd.LoadJobsConfig(logger, *flagNS1SDJobsConfigFile)
+		if err != nil {
+			logger.Error("Failed to load service discovery jobs config file", "err", err, "path", *flagNS1SDJobsConfigFile)
+			os.Exit(1)
+		}
+	}
+	if *flagNS1SDFileSDDir != "" {
+		if !*flagNS1EnableSD {
+			logger.Error("--ns1.sd-file-sd-dir requires --ns1.enable-service-discovery")
+			os.Exit(1)
+		}
+
+		sdWorker.FileSD, err = sd.NewFileSDWriter(logger, *flagNS1SDFileSDDir, sd.FileSDFormat(*flagNS1SDFileSDFormat))
+		if err != nil {
+			logger.Error("Failed to set up file_sd", "err", err, "path", *flagNS1SDFileSDDir)
+			os.Exit(1)
+		}
+	}
+	if *flagNS1SDEnableFilterSimulation && !*flagNS1EnableSD {
+		logger.Error("--ns1.sd-enable-filter-simulation requires --ns1.enable-service-discovery")
+		os.Exit(1)
+	}
+	if *flagNS1SDEnableConsulAPI && !*flagNS1EnableSD {
+		logger.Error("--ns1.sd-enable-consul-api requires --ns1.enable-service-discovery")
+		os.Exit(1)
+	}
+	if *flagNS1SDEnableTagsInfo {
+		if !*flagNS1EnableSD {
+			logger.Error("--ns1.sd-enable-tags-info requires --ns1.enable-service-discovery")
+			os.Exit(1)
+		}
+
+		// register SD worker for collection of tag info metrics
+		metrics.Registry.MustRegister(sdWorker)
+	}
+
+	if *flagStoragePath != "" {
+		store, err := storage.New(logger, *flagStoragePath)
+		if err != nil {
+			logger.Error("Failed to initialize storage", "err", err, "storage_path", *flagStoragePath)
+			os.Exit(1)
+		}
+
+		// load snapshots before starting the refresh routines and web
+		// server, so that cached data is served immediately while the
+		// first refresh from the NS1 API runs in the background
+		exporterWorker.Storage = store
+		exporterWorker.LoadSnapshot()
+		if *flagNS1EnableSD {
+			sdWorker.Storage = store
+			sdWorker.LoadSnapshot()
+		}
+	}
 
 	var g run.Group
 	{
@@ -189,6 +536,20 @@ func Run(logger *slog.Logger) {
 			},
 		)
 	}
+	if apiKeyFile != nil {
+		// reload the API key file so that key rotations take effect
+		// without a restart
+		cancel := make(chan struct{})
+		g.Add(
+			func() error {
+				apiKeyFile.Watch(*flagNS1APIKeyFileReloadInterval, cancel)
+				return nil
+			},
+			func(error) {
+				close(cancel)
+			},
+		)
+	}
 	{
 		// ticker routine to refresh metrics from NS1 api to serve with exporter
 		cancel := make(chan struct{})
@@ -282,7 +643,9 @@ func Run(logger *slog.Logger) {
 				return nil
 			},
 			func(error) {
-				if err := server.Shutdown(context.Background()); err != nil {
+				ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+				defer cancelShutdown()
+				if err := server.Shutdown(ctx); err != nil {
 					// Error from closing listeners, or context timeout:
 					logger.Error("Failed to close listeners/context timeout", "err", err)
 				}
@@ -298,6 +661,107 @@ func Run(logger *slog.Logger) {
 	logger.Info(programName + " finished. See you next time!")
 }
 
+// setupBackend creates the base NS1 backend used by the workers, which is
+// either the NS1 API itself (optionally recording responses) or a replay of
+// previously recorded responses. If the API key is loaded from a file, the
+// APIKeyFile is also returned so that it can be watched for changes.
+func setupBackend(logger *slog.Logger) (ns1.Backend, *ns1.APIKeyFile) {
+	if *flagNS1RecordDir != "" && *flagNS1ReplayDir != "" {
+		logger.Error("--ns1.record-dir and --ns1.replay-dir are mutually exclusive")
+		os.Exit(1)
+	}
+
+	if *flagNS1ReplayDir != "" {
+		backend, err := ns1.NewReplayBackend(*flagNS1ReplayDir)
+		if err != nil {
+			logger.Error("Failed to initialize NS1 API replay", "err", err, "replay_dir", *flagNS1ReplayDir)
+			os.Exit(1)
+		}
+		logger.Warn("Replaying recorded NS1 API responses, no requests will be made to the NS1 API", "replay_dir", *flagNS1ReplayDir)
+
+		return backend, nil
+	}
+
+	var (
+		apiKey     *ns1.APIKey
+		apiKeyFile *ns1.APIKeyFile
+	)
+	switch {
+	case *flagNS1APIKeyFile != "":
+		if os.Getenv("NS1_APIKEY") != "" {
+			logger.Warn("Both --ns1.api-key-file and NS1_APIKEY environment variable are set, using API key file")
+		}
+
+		var err error
+		apiKeyFile, err = ns1.NewAPIKeyFile(logger, *flagNS1APIKeyFile)
+		if err != nil {
+			logger.Error("Failed to load NS1 API key file", "err", err, "api_key_file", *flagNS1APIKeyFile)
+			os.Exit(1)
+		}
+		apiKey = apiKeyFile.Key()
+	case os.Getenv("NS1_APIKEY") != "":
+		apiKey = ns1.NewAPIKey(logger, os.Getenv("NS1_APIKEY"))
+	default:
+		logger.Error("NS1 API key not set, either --ns1.api-key-file or NS1_APIKEY environment variable is required")
+		os.Exit(1)
+	}
+
+	apiClient, err := ns1.NewClient(ns1.APIConfig{
+		Key:           apiKey,
+		Concurrency:   *flagNS1Concurrency,
+		Endpoint:      *flagNS1Endpoint,
+		TLSSkipVerify: *flagNS1TLSInsecureSkipVerify,
+		TLSCAFile:     *flagNS1TLSCAFile,
+		TLSCertFile:   *flagNS1TLSCertFile,
+		TLSKeyFile:    *flagNS1TLSKeyFile,
+		ProxyURL:      *flagNS1ProxyURL,
+		Timeout:       *flagNS1HTTPTimeout,
+		UserAgent:     "ns1_exporter/" + version.Version,
+	})
+	if err != nil {
+		logger.Error("Failed to create NS1 API client", "err", err)
+		os.Exit(1)
+	}
+
+	var backend ns1.Backend = ns1.NewAPIBackend(apiClient)
+	if *flagNS1RecordDir != "" {
+		recorder, err := ns1.NewFileRecorder(logger, *flagNS1RecordDir)
+		if err != nil {
+			logger.Error("Failed to initialize NS1 API recording", "err", err, "record_dir", *flagNS1RecordDir)
+			os.Exit(1)
+		}
+		logger.Info("Recording sanitized NS1 API responses", "record_dir", *flagNS1RecordDir)
+
+		backend = ns1.NewRecordingBackend(backend, recorder)
+	}
+
+	return backend, apiKeyFile
+}
+
+// setupRecordFilter creates the exporter's record filter from the
+// corresponding flags.
+func setupRecordFilter(logger *slog.Logger) *ns1.RecordFilter {
+	tags, err := ns1.ParseMatchers(*flagNS1ExporterRecordTags)
+	if err != nil {
+		logger.Error("Failed to parse record tag filters", "err", err)
+		os.Exit(1)
+	}
+
+	meta, err := ns1.ParseMatchers(*flagNS1ExporterRecordMeta)
+	if err != nil {
+		logger.Error("Failed to parse record meta filters", "err", err)
+		os.Exit(1)
+	}
+
+	return &ns1.RecordFilter{
+		DomainBlacklist: *flagNS1ExporterRecordDomainBlacklistRegex,
+		DomainWhitelist: *flagNS1ExporterRecordDomainWhitelistRegex,
+		TypeWhitelist:   *flagNS1ExporterRecordTypeRegex,
+		Tags:            tags,
+		Meta:            meta,
+	}
+}
+
 func setupServer(logger *slog.Logger, sdWorker *sd.Worker) *http.Server {
 	server := &http.Server{
 		ReadTimeout:  30 * time.Second,
@@ -335,6 +799,36 @@ func setupServer(logger *slog.Logger, sdWorker *sd.Worker) *http.Server {
 		)
 
 		http.Handle("/sd", sdWorker)
+
+		for _, job := range sdWorker.Jobs {
+			jobPath := "/sd/" + job.Name
+			landingPageLinks = append(landingPageLinks,
+				web.LandingLinks{
+					Address: jobPath,
+					Text:    "Service Discovery Job: " + job.Name,
+				},
+			)
+
+			http.Handle(jobPath, job)
+		}
+
+		if *flagNS1SDEnableFilterSimulation {
+			http.Handle("/debug/ns1/simulate", sd.NewFilterSimulator(sdWorker))
+		}
+
+		if *flagNS1SDEnableConsulAPI {
+			landingPageLinks = append(landingPageLinks,
+				web.LandingLinks{
+					Address: "/v1/catalog/services",
+					Text:    "Consul Catalog Services",
+				},
+			)
+
+			// blocking queries must not delay shutdown
+			catalog := sd.NewConsulCatalog(sdWorker, *flagNS1SDConsulDatacenter, *flagNS1SDConsulServicePort)
+			server.RegisterOnShutdown(catalog.Close)
+			http.Handle("/v1/", catalog)
+		}
 	}
 
 	if *flagWebTelemetryPath != "/" {
@@ -0,0 +1,94 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/common/promslog"
+	"github.com/prometheus/common/promslog/flag"
+
+	"github.com/tjhop/ns1_exporter/internal/version"
+	"github.com/tjhop/ns1_exporter/pkg/simulator"
+)
+
+const (
+	programName = "ns1_simulator"
+)
+
+var (
+	flagListenAddress = kingpin.Flag(
+		"web.listen-address",
+		"Address on which to serve the simulated NS1 API. Point the exporter at it with `--ns1.endpoint=http://<address>/v1/`.",
+	).Default("127.0.0.1:8081").String()
+
+	flagConfigFile = kingpin.Flag(
+		"config.file",
+		"Path to the simulator configuration file describing zones, records, QPS curves, activity events and injected faults.",
+	).Required().String()
+)
+
+func main() {
+	promslogConfig := &promslog.Config{}
+	flag.AddFlags(kingpin.CommandLine, promslogConfig)
+	kingpin.Version(version.Print(programName))
+	kingpin.CommandLine.UsageWriter(os.Stdout)
+	kingpin.HelpFlag.Short('h')
+	kingpin.CommandLine.DefaultEnvars()
+	kingpin.Parse()
+	logger := promslog.New(promslogConfig)
+
+	logger.Info("Starting "+programName, "version", version.Version, "build_date", version.BuildDate, "commit", version.Commit, "go_version", runtime.Version())
+
+	config, err := simulator.LoadConfig(*flagConfigFile)
+	if err != nil {
+		logger.Error("Failed to load simulator config", "err", err, "config_file", *flagConfigFile)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:         *flagListenAddress,
+		Handler:      simulator.New(logger, config),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  30 * time.Second,
+	}
+
+	go func() {
+		term := make(chan os.Signal, 1)
+		signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+		sig := <-term
+		logger.Warn("Caught signal, exiting gracefully.", "signal", sig.String())
+
+		if err := server.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to shut down simulator", "err", err)
+		}
+	}()
+
+	logger.Info("Serving simulated NS1 API", "address", *flagListenAddress, "num_zones", len(config.Zones))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Failed to run simulator", "err", err)
+		os.Exit(1)
+	}
+	logger.Info(programName + " finished. See you next time!")
+}
@@ -15,18 +15,46 @@
 package exporter
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
 	api "gopkg.in/ns1/ns1-go.v2/rest"
 
 	"github.com/tjhop/ns1_exporter/internal/version"
 	"github.com/tjhop/ns1_exporter/pkg/metrics"
 	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+	"github.com/tjhop/ns1_exporter/pkg/storage"
 )
 
+const (
+	snapshotName = "exporter"
+
+	// OtherRecordName is the record name of the per-zone series that
+	// record-level QPS is folded into when limited by RecordQPSTopN or
+	// RecordQPSMinimum.
+	OtherRecordName = "__other__"
+)
+
+// snapshot is the subset of the worker's state that is persisted to disk when
+// storage is enabled.
+type snapshot struct {
+	Zones map[string]*ns1_internal.Zone `json:"zones"`
+	QPS   []*ns1_internal.QPS           `json:"qps"`
+}
+
 // Worker is a struct containing configs needed to retrieve stats from NS1 API
 // to expose as prometheus metrics. It implements the prometheus.Collector
 // interface.
@@ -35,15 +63,110 @@ type Worker struct {
 	EnableRecordQPS bool
 	ZoneBlacklist   *regexp.Regexp
 	ZoneWhitelist   *regexp.Regexp
+	// Views configures the lookup of each zone's NS1 DNS views, and
+	// filtering of zones by view and network.
+	Views ns1_internal.ViewOptions
+	// RecordFilter is optional. When set, record-level QPS is only
+	// collected for records that match it.
+	RecordFilter *ns1_internal.RecordFilter
+	// RecordQPSTopN and RecordQPSMinimum limit the cardinality of
+	// record-level QPS metrics. Only the top N records by QPS in each
+	// zone, and only records with at least the minimum QPS, are kept as
+	// their own series. The QPS of the remaining records is summed into a
+	// single series per zone with the record name OtherRecordName. Zero
+	// values disable the respective limit.
+	RecordQPSTopN    int
+	RecordQPSMinimum float64
+	// Storage is optional. When set, the worker persists its caches after
+	// each refresh and can restore them on startup via LoadSnapshot().
+	Storage *storage.Store
+
+	// The caches are only accessed by the goroutine refreshing them.
+	// Collect reads the cacheSet published after each refresh instead.
+	logger      *slog.Logger
+	client      ns1_internal.Backend
+	labels      LabelConfig
+	qpsDesc     *prometheus.Desc
+	zoneCache   map[string]*ns1_internal.Zone
+	qpsCache    []*ns1_internal.QPS
+	foldedCache map[string]int
+	// metaMatches caches whether the meta of each record matches the
+	// record filter, keyed by recordKey, so that records are only looked
+	// up again when account activity since metaRefreshed may have changed
+	// them.
+	metaMatches   map[string]metaMatch
+	metaRefreshed time.Time
+	metaPollCount int
+	// published is the immutable cacheSet of the most recent refresh.
+	published atomic.Pointer[cacheSet]
+}
+
+// metaMatch is the cached result of matching the meta of a record against the
+// worker's record filter.
+type metaMatch struct {
+	recordID string
+	match    bool
+}
+
+// cacheSet is the state needed to collect the worker's metrics.
+type cacheSet struct {
+	zones  map[string]*ns1_internal.Zone
+	qps    []*ns1_internal.QPS
+	folded map[string]int
+}
 
-	logger    *slog.Logger
-	client    *api.Client
-	zoneCache map[string]*ns1_internal.Zone
-	qpsCache  []*ns1_internal.QPS
+// LabelConfig configures the labels of the worker's QPS metrics.
+type LabelConfig struct {
+	// TagLabels are NS1 zone/record tags that are added as labels.
+	TagLabels []TagLabel
+	// ViewLabels adds the views and networks of each zone as labels, as
+	// comma separated lists.
+	ViewLabels bool
+	// RelabelConfigs are applied to each QPS series, after tag labels
+	// are added. When set, the label names of QPS series can vary, so
+	// the worker is registered as an unchecked collector.
+	RelabelConfigs []*relabel.Config
 }
 
-// NewWorker creates a new Worker struct to collect data from the NS1 API.
-func NewWorker(logger *slog.Logger, client *api.Client, zoneEnabled, recordEnabled bool, blacklist, whitelist *regexp.Regexp) *Worker {
+// TagLabel maps an NS1 zone/record tag to a label on QPS metrics.
+type TagLabel struct {
+	Tag   string
+	Label string
+}
+
+// ParseTagLabels parses a list of `tag` or `tag=label` strings into TagLabels.
+// If no label name is given, the tag name is used as the label name.
+func ParseTagLabels(tagLabels []string) ([]TagLabel, error) {
+	var parsed []TagLabel
+	seen := make(map[string]struct{})
+	for _, tl := range tagLabels {
+		tag, label, ok := strings.Cut(tl, "=")
+		if !ok {
+			label = tag
+		}
+
+		if tag == "" {
+			return nil, fmt.Errorf("invalid tag label %q, expected tag or tag=label", tl)
+		}
+
+		if !model.LabelName(label).IsValidLegacy() || strings.HasPrefix(label, "__") {
+			return nil, fmt.Errorf("invalid label name %q for tag %q", label, tag)
+		}
+
+		if _, ok := seen[label]; ok || slices.Contains(metrics.QPSLabels, label) || slices.Contains(metrics.QPSViewLabels, label) {
+			return nil, fmt.Errorf("duplicate label name %q for tag %q", label, tag)
+		}
+		seen[label] = struct{}{}
+
+		parsed = append(parsed, TagLabel{Tag: tag, Label: label})
+	}
+
+	return parsed, nil
+}
+
+// NewWorker creates a new Worker struct to collect data from the NS1 API. The
+// provided label config determines the labels of the worker's QPS metrics.
+func NewWorker(logger *slog.Logger, client ns1_internal.Backend, zoneEnabled, recordEnabled bool, blacklist, whitelist *regexp.Regexp, labels LabelConfig) *Worker {
 	worker := &Worker{
 		EnableZoneQPS:   zoneEnabled,
 		EnableRecordQPS: recordEnabled,
@@ -51,6 +174,12 @@ func NewWorker(logger *slog.Logger, client *api.Client, zoneEnabled, recordEnabl
 		ZoneWhitelist:   whitelist,
 		client:          client,
 		logger:          logger.With("worker", "exporter"),
+		labels:          labels,
+		qpsDesc:         metrics.MetricQPSDesc,
+	}
+
+	if len(labels.TagLabels) > 0 || labels.ViewLabels {
+		worker.qpsDesc = metrics.NewQPSDesc(worker.qpsLabelNames())
 	}
 
 	// register exporter worker for metrics collection
@@ -59,10 +188,19 @@ func NewWorker(logger *slog.Logger, client *api.Client, zoneEnabled, recordEnabl
 	return worker
 }
 
-// Describe implements the prometheus.Collector interface.
+// Describe implements the prometheus.Collector interface. If relabeling is
+// configured, no metrics are described, which makes the worker an unchecked
+// collector, since relabeling can change the label names of QPS series.
 func (w *Worker) Describe(ch chan<- *prometheus.Desc) {
+	if len(w.labels.RelabelConfigs) > 0 {
+		return
+	}
+
 	ch <- metrics.MetricBuildInfoDesc
-	ch <- metrics.MetricQPSDesc
+	ch <- w.qpsDesc
+	ch <- metrics.MetricQPSFoldedSeriesDesc
+	ch <- metrics.MetricZoneDataAgeDesc
+	ch <- metrics.MetricZoneStaleDesc
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -72,10 +210,34 @@ func (w *Worker) Collect(ch chan<- prometheus.Metric) {
 		metrics.MetricBuildInfoDesc, prometheus.GaugeValue, 1, version.Version, version.BuildDate, version.Commit,
 	)
 
+	set := w.current()
+
 	// qps metrics
-	for _, qps := range w.qpsCache {
+	w.collectQPS(ch, set)
+
+	for zName, folded := range set.folded {
 		ch <- prometheus.MustNewConstMetric(
-			metrics.MetricQPSDesc, prometheus.GaugeValue, float64(qps.Value), qps.ZoneName, qps.RecordName, qps.RecordType,
+			metrics.MetricQPSFoldedSeriesDesc, prometheus.GaugeValue, float64(folded), zName,
+		)
+	}
+
+	// zone data freshness metrics, only available when zone data is
+	// retrieved per zone
+	for zName, zData := range set.zones {
+		if zData.LastUpdated.IsZero() {
+			continue
+		}
+
+		stale := 0.0
+		if zData.Stale {
+			stale = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			metrics.MetricZoneDataAgeDesc, prometheus.GaugeValue, time.Since(zData.LastUpdated).Seconds(), zName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			metrics.MetricZoneStaleDesc, prometheus.GaugeValue, stale, zName,
 		)
 	}
 }
@@ -83,7 +245,13 @@ func (w *Worker) Collect(ch chan<- prometheus.Metric) {
 // RefreshZoneData updates the data for each of the zones in the worker's zone list by querying the NS1 API, parses the data to structs that serve as internal counterparts to the NS1 API's dns.Record and dns.Zone, and then updating the worker's internal map of zones. This internal map is used as a cache to respond to respond to HTTP requests.
 func (w *Worker) RefreshZoneData() {
 	getRecords := w.EnableRecordQPS || w.EnableZoneQPS
-	w.zoneCache = ns1_internal.RefreshZoneData(w.logger, w.client, getRecords, w.ZoneBlacklist, w.ZoneWhitelist)
+	zones, err := ns1_internal.RefreshZoneData(w.logger, w.client, getRecords, w.ZoneBlacklist, w.ZoneWhitelist, w.Views, w.zoneCache)
+	if err != nil {
+		w.logger.Error("Failed to refresh zone data, keeping previous zone cache", "err", err, "num_zones", len(w.zoneCache))
+		return
+	}
+	w.zoneCache = zones
+	w.publish()
 	w.logger.Debug("Worker zone cache updated", "num_zones", len(w.zoneCache))
 
 	if getRecords {
@@ -118,7 +286,7 @@ func (w *Worker) RefreshQPSAccountData() {
 	cache := make([]*ns1_internal.QPS, 1)
 
 	w.logger.Debug("Refreshing account-level qps data from NS1 API")
-	qpsRaw, _, err := w.client.Stats.GetQPS()
+	qpsRaw, err := w.client.GetQPS()
 	if err != nil {
 		w.logger.Error("Failed to get account-level qps data from NS1 API", "err", err)
 		metrics.MetricExporterNS1APIFailures.Inc()
@@ -128,6 +296,7 @@ func (w *Worker) RefreshQPSAccountData() {
 		Value: qpsRaw,
 	}
 	w.qpsCache = cache
+	w.publish()
 	w.logger.Debug("Worker QPS cache updated", "qps_level", "account")
 }
 
@@ -137,7 +306,7 @@ func (w *Worker) RefreshQPSZoneData() {
 
 	for zName := range w.zoneCache {
 		w.logger.Debug("Refreshing zone-level qps data from NS1 API", "zone_name", zName)
-		zoneQPSRaw, _, err := w.client.Stats.GetZoneQPS(zName)
+		zoneQPSRaw, err := w.client.GetZoneQPS(zName)
 		if err != nil {
 			w.logger.Error("Failed to get zone-level qps data from NS1 API", "err", err, "zone_name", zName)
 			metrics.MetricExporterNS1APIFailures.Inc()
@@ -146,10 +315,12 @@ func (w *Worker) RefreshQPSZoneData() {
 		cache = append(cache, &ns1_internal.QPS{
 			Value:    zoneQPSRaw,
 			ZoneName: zName,
+			Tags:     w.qpsTags(zName, nil),
 		})
 		w.logger.Debug("Worker QPS cache updated", "qps_level", "zone", "zone", zName)
 	}
 	w.qpsCache = cache
+	w.publish()
 }
 
 // RefreshQPSRecordData refreshes the worker's `[]*ns1_internal.QPS` cache array by using the zone/record information present in the worker's `map[string]*ns1_internal.Zone` cache map.
@@ -163,10 +334,20 @@ func (w *Worker) RefreshQPSRecordData() {
 
 	var cache []*ns1_internal.QPS
 
+	seen := make(map[string]struct{}, numRecords)
+	if w.RecordFilter.NeedsMeta() {
+		w.refreshMetaMatches(time.Now().UTC())
+	}
+
 	for zName, zData := range w.zoneCache {
 		for _, r := range zData.Records {
+			seen[recordKey(zName, r)] = struct{}{}
+			if !w.matchRecord(zName, r) {
+				continue
+			}
+
 			w.logger.Debug("Refreshing record-level qps data from NS1 API", "zone_name", zName, "record_domain", r.Domain, "record_type", r.Type)
-			recordQPSRaw, _, err := w.client.Stats.GetRecordQPS(zName, r.Domain, r.Type)
+			recordQPSRaw, err := w.client.GetRecordQPS(zName, r.Domain, r.Type)
 			if err != nil {
 				w.logger.Error("Failed to get record-level qps data for from NS1 API", "err", err, "zone_name", zName, "record_name", r.Domain, "record_type", r.Type)
 				metrics.MetricExporterNS1APIFailures.Inc()
@@ -177,11 +358,280 @@ func (w *Worker) RefreshQPSRecordData() {
 				ZoneName:   zName,
 				RecordName: r.Domain,
 				RecordType: r.Type,
+				Tags:       w.qpsTags(zName, r),
 			})
 		}
 		w.logger.Debug("Worker QPS cache updated", "qps_level", "zone", "zone", zName, "num_records", len(zData.Records))
 	}
+
+	// records that no longer exist don't need their meta matches
+	maps.DeleteFunc(w.metaMatches, func(key string, _ metaMatch) bool {
+		_, ok := seen[key]
+		return !ok
+	})
+
+	if w.RecordQPSTopN > 0 || w.RecordQPSMinimum > 0 {
+		var folded map[string]int
+		cache, folded = foldRecordQPS(cache, w.RecordQPSTopN, w.RecordQPSMinimum)
+		w.foldedCache = folded
+		for _, qps := range cache {
+			if qps.RecordName == OtherRecordName {
+				qps.Tags = w.qpsTags(qps.ZoneName, nil)
+			}
+		}
+		w.logger.Debug("Folded record-level qps data", "num_series", len(cache))
+	}
 	w.qpsCache = cache
+	w.publish()
+}
+
+// publish publishes the worker's caches for collection. The published caches
+// must not be modified afterwards, refreshes replace them instead.
+func (w *Worker) publish() {
+	w.published.Store(&cacheSet{
+		zones:  w.zoneCache,
+		qps:    w.qpsCache,
+		folded: w.foldedCache,
+	})
+}
+
+// current returns the most recently published cacheSet. Before the first
+// refresh, it returns an empty set.
+func (w *Worker) current() *cacheSet {
+	if set := w.published.Load(); set != nil {
+		return set
+	}
+
+	return &cacheSet{}
+}
+
+// qpsLabelNames returns the label names of QPS metrics, before relabeling.
+func (w *Worker) qpsLabelNames() []string {
+	names := slices.Clone(metrics.QPSLabels)
+	if w.labels.ViewLabels {
+		names = append(names, metrics.QPSViewLabels...)
+	}
+	for _, tl := range w.labels.TagLabels {
+		names = append(names, tl.Label)
+	}
+
+	return names
+}
+
+// collectQPS sends the QPS data of a published cacheSet as metrics, applying
+// any configured relabeling.
+func (w *Worker) collectQPS(ch chan<- prometheus.Metric, set *cacheSet) {
+	names := w.qpsLabelNames()
+	seen := make(map[string]struct{}, len(set.qps))
+
+	for _, qps := range set.qps {
+		labelValues := []string{qps.ZoneName, qps.RecordName, qps.RecordType}
+		if w.labels.ViewLabels {
+			labelValues = append(labelValues, viewLabelValues(set.zones, qps.ZoneName)...)
+		}
+		for _, tl := range w.labels.TagLabels {
+			labelValues = append(labelValues, qps.Tags[tl.Tag])
+		}
+
+		if len(w.labels.RelabelConfigs) == 0 {
+			ch <- prometheus.MustNewConstMetric(
+				w.qpsDesc, prometheus.GaugeValue, float64(qps.Value), labelValues...,
+			)
+			continue
+		}
+
+		relabeledNames, relabeledValues, keep := relabelSeries(metrics.QPSMetricName, names, labelValues, w.labels.RelabelConfigs)
+		if !keep {
+			continue
+		}
+
+		// relabeling may map multiple series to the same labels, which
+		// can't be exposed more than once
+		key := strings.Join(relabeledNames, "\xff") + "\xfe" + strings.Join(relabeledValues, "\xff")
+		if _, ok := seen[key]; ok {
+			w.logger.Debug("Dropping QPS series with duplicate labels after relabeling", "zone_name", qps.ZoneName, "record_name", qps.RecordName, "record_type", qps.RecordType)
+			continue
+		}
+		seen[key] = struct{}{}
+
+		ch <- prometheus.MustNewConstMetric(
+			metrics.NewQPSDesc(relabeledNames), prometheus.GaugeValue, float64(qps.Value), relabeledValues...,
+		)
+	}
+}
+
+// viewLabelValues returns the values of the view labels of a zone's QPS
+// metrics: its comma separated views and networks. Account-level QPS isn't
+// associated with a zone, so its view labels are empty.
+func viewLabelValues(zones map[string]*ns1_internal.Zone, zone string) []string {
+	zData, ok := zones[zone]
+	if !ok {
+		return []string{"", ""}
+	}
+
+	return []string{strings.Join(zData.Views, ","), ns1_internal.JoinNetworkIDs(zData.NetworkIDs)}
+}
+
+// qpsTags returns the tags of a zone, and optionally one of its records, that
+// are exposed as labels on QPS metrics. Record tags take precedence over zone
+// tags with the same name.
+func (w *Worker) qpsTags(zone string, r *ns1_internal.ZoneRecord) map[string]string {
+	if len(w.labels.TagLabels) == 0 {
+		return nil
+	}
+
+	var zoneTags map[string]string
+	if zData, ok := w.zoneCache[zone]; ok {
+		zoneTags = zData.Tags
+	}
+
+	tags := make(map[string]string, len(w.labels.TagLabels))
+	for _, tl := range w.labels.TagLabels {
+		if r != nil {
+			if value, ok := r.Tags[tl.Tag]; ok {
+				tags[tl.Tag] = value
+				continue
+			}
+		}
+		if value, ok := zoneTags[tl.Tag]; ok {
+			tags[tl.Tag] = value
+		}
+	}
+
+	return tags
+}
+
+// matchRecord returns whether record-level QPS should be collected for a
+// record, according to the worker's record filter. Records are only looked up
+// to match their meta if no match is cached.
+func (w *Worker) matchRecord(zone string, r *ns1_internal.ZoneRecord) bool {
+	if !w.RecordFilter.Match(r) {
+		w.logger.Debug("skipping record because it doesn't match record filter", "zone_name", zone, "record_domain", r.Domain, "record_type", r.Type)
+		return false
+	}
+
+	if !w.RecordFilter.NeedsMeta() {
+		return true
+	}
+
+	key := recordKey(zone, r)
+	m, ok := w.metaMatches[key]
+	if !ok {
+		record, err := w.client.GetRecord(zone, r.Domain, r.Type)
+		if err != nil {
+			w.logger.Error("Failed to get record data from NS1 API to filter by meta, skipping record", "err", err, "zone_name", zone, "record_domain", r.Domain, "record_type", r.Type)
+			metrics.MetricExporterNS1APIFailures.Inc()
+			return false
+		}
+
+		m = metaMatch{recordID: record.ID, match: w.RecordFilter.MatchMeta(record.Meta)}
+		w.metaMatches[key] = m
+	}
+
+	if !m.match {
+		w.logger.Debug("skipping record because its meta doesn't match record filter", "zone_name", zone, "record_domain", r.Domain, "record_type", r.Type)
+		return false
+	}
+
+	return true
+}
+
+// refreshMetaMatches drops the cached meta matches of records that account
+// activity since the previous refresh may have changed. Record activity only
+// drops the match of the changed record, other zone activity drops all
+// matches. All matches are also dropped every 10 polls, in case activity was
+// missed.
+func (w *Worker) refreshMetaMatches(now time.Time) {
+	if w.metaMatches == nil || w.metaPollCount >= 10 {
+		w.metaMatches = make(map[string]metaMatch)
+		w.metaRefreshed = now
+		w.metaPollCount = 0
+		return
+	}
+
+	params := []api.Param{
+		{Key: "start", Value: strconv.FormatInt(w.metaRefreshed.Unix(), 10)},
+		{Key: "limit", Value: "1000"},
+	}
+	w.logger.Debug("Refreshing account activity from NS1 API")
+	activity, err := w.client.ListActivity(params...)
+	if err != nil {
+		// keep the cached matches and poll from the same start time
+		// again on the next refresh, so that no activity is missed
+		w.logger.Error("Failed to get account activity from NS1 API, keeping cached record meta matches", "err", err)
+		metrics.MetricExporterNS1APIFailures.Inc()
+		return
+	}
+	w.metaRefreshed = now
+	w.metaPollCount++
+
+	for _, a := range activity {
+		if !ns1_internal.IsZoneActivity(a) {
+			continue
+		}
+
+		if a.ResourceType != "record" {
+			clear(w.metaMatches)
+			return
+		}
+
+		maps.DeleteFunc(w.metaMatches, func(_ string, m metaMatch) bool {
+			return m.recordID == a.ResourceID
+		})
+	}
+}
+
+// recordKey identifies a record of a zone in the worker's meta match cache.
+func recordKey(zone string, r *ns1_internal.ZoneRecord) string {
+	return zone + "/" + r.Domain + "/" + r.Type
+}
+
+// foldRecordQPS limits the number of record-level QPS series per zone to the
+// top N records by QPS, and to records with at least the minimum QPS. The QPS
+// of all other records in a zone is summed into a single series with the
+// record name OtherRecordName. It returns the limited QPS data along with the
+// number of records folded per zone.
+func foldRecordQPS(cache []*ns1_internal.QPS, topN int, minimum float64) ([]*ns1_internal.QPS, map[string]int) {
+	byZone := make(map[string][]*ns1_internal.QPS)
+	var zones []string
+	for _, qps := range cache {
+		if _, ok := byZone[qps.ZoneName]; !ok {
+			zones = append(zones, qps.ZoneName)
+		}
+		byZone[qps.ZoneName] = append(byZone[qps.ZoneName], qps)
+	}
+
+	folded := make(map[string]int, len(zones))
+	limited := make([]*ns1_internal.QPS, 0, len(cache))
+	for _, zName := range zones {
+		records := byZone[zName]
+		// sort by QPS, breaking ties by name so that the kept series
+		// don't change between refreshes when values are equal
+		slices.SortStableFunc(records, func(a, b *ns1_internal.QPS) int {
+			if c := cmp.Compare(b.Value, a.Value); c != 0 {
+				return c
+			}
+			return cmp.Or(cmp.Compare(a.RecordName, b.RecordName), cmp.Compare(a.RecordType, b.RecordType))
+		})
+
+		other := &ns1_internal.QPS{ZoneName: zName, RecordName: OtherRecordName}
+		for i, qps := range records {
+			if (topN > 0 && i >= topN) || float64(qps.Value) < minimum {
+				other.Value += qps.Value
+				folded[zName]++
+				continue
+			}
+			limited = append(limited, qps)
+		}
+
+		if folded[zName] > 0 {
+			limited = append(limited, other)
+		} else {
+			folded[zName] = 0
+		}
+	}
+
+	return limited, folded
 }
 
 // Refresh calls the other Refresh* functions as needed to update the worker's data from the NS1 API.
@@ -190,4 +640,49 @@ func (w *Worker) Refresh() {
 	w.RefreshZoneData()
 	w.logger.Info("Updating QPS data from NS1 API")
 	w.RefreshQPSData()
+	w.SaveSnapshot()
+}
+
+// LoadSnapshot restores the worker's zone and QPS caches from the most recent
+// on-disk snapshot, if storage is enabled and a usable snapshot exists. This
+// allows the worker to serve metrics immediately on startup while the first
+// refresh from the NS1 API runs in the background.
+func (w *Worker) LoadSnapshot() {
+	if w.Storage == nil {
+		return
+	}
+
+	var snap snapshot
+	ts, err := w.Storage.Load(snapshotName, &snap)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			w.logger.Info("No snapshot found on disk, starting with empty caches")
+			return
+		}
+		w.logger.Warn("Failed to load snapshot from disk, starting with empty caches", "err", err)
+		return
+	}
+
+	w.zoneCache = snap.Zones
+	w.qpsCache = snap.QPS
+	w.publish()
+	w.logger.Info("Loaded snapshot from disk", "snapshot_time", ts, "num_zones", len(w.zoneCache), "num_qps", len(w.qpsCache))
+}
+
+// SaveSnapshot persists the worker's zone and QPS caches to disk, if storage
+// is enabled. Empty caches are not saved, to avoid replacing the last good
+// snapshot with the result of a failed refresh.
+func (w *Worker) SaveSnapshot() {
+	if w.Storage == nil {
+		return
+	}
+
+	if len(w.zoneCache) == 0 && len(w.qpsCache) == 0 {
+		w.logger.Debug("Worker caches are empty, skipping snapshot")
+		return
+	}
+
+	if err := w.Storage.Save(snapshotName, snapshot{Zones: w.zoneCache, QPS: w.qpsCache}); err != nil {
+		w.logger.Error("Failed to save snapshot to disk", "err", err)
+	}
 }
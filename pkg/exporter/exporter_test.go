@@ -17,14 +17,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promslog"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/ns1/ns1-go.v2/mockns1"
 	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
 
 	"github.com/tjhop/ns1_exporter/pkg/metrics"
 	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
@@ -73,7 +79,7 @@ ns1_stats_queries_per_second{record_name="",record_type="",zone_name=""} 10000
 `
 
 	for name, tc := range tests {
-		worker := NewWorker(mockLogger, mockClient, false, false, nil, nil)
+		worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), false, false, nil, nil, LabelConfig{})
 		worker.zoneCache = mockZoneCache
 
 		t.Run(name, func(t *testing.T) {
@@ -126,7 +132,7 @@ ns1_stats_queries_per_second{record_name="",record_type="",zone_name="keep.me"}
 `
 
 	for name, tc := range tests {
-		worker := NewWorker(mockLogger, mockClient, true, false, nil, nil)
+		worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, false, nil, nil, LabelConfig{})
 		worker.zoneCache = mockZoneCache
 
 		t.Run(name, func(t *testing.T) {
@@ -185,7 +191,7 @@ ns1_stats_queries_per_second{record_name="test.keep.me",record_type="A",zone_nam
 `
 
 	for name, tc := range tests {
-		worker := NewWorker(mockLogger, mockClient, true, true, nil, nil)
+		worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, true, nil, nil, LabelConfig{})
 		worker.zoneCache = mockZoneCache
 
 		t.Run(name, func(t *testing.T) {
@@ -208,3 +214,392 @@ ns1_stats_queries_per_second{record_name="test.keep.me",record_type="A",zone_nam
 		metrics.Registry.Unregister(worker)
 	}
 }
+
+func TestRefreshQPSRecordDataFilter(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	want := []*ns1_internal.QPS{
+		{Value: float32(2500), ZoneName: "foo.bar", RecordName: "test.foo.bar", RecordType: "A"},
+	}
+	for _, qps := range want {
+		require.NoError(t, mock.AddTestCase(http.MethodGet, fmt.Sprintf("stats/qps/%s/%s/%s", qps.ZoneName, qps.RecordName, qps.RecordType),
+			http.StatusOK, nil, nil, "", struct{ QPS float32 }{QPS: qps.Value}),
+		)
+	}
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, true, nil, nil, LabelConfig{})
+	defer metrics.Registry.Unregister(worker)
+	worker.zoneCache = mockZoneCache
+	worker.RecordFilter = &ns1_internal.RecordFilter{
+		DomainBlacklist: regexp.MustCompile("keep"),
+		TypeWhitelist:   regexp.MustCompile("^A$"),
+	}
+
+	worker.RefreshQPSRecordData()
+	require.Equal(t, want, worker.qpsCache)
+}
+
+func TestRefreshZoneDataListFailure(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	require.NoError(t, mock.AddTestCase(http.MethodGet, "zones", http.StatusInternalServerError, nil, nil, "", map[string]string{"message": "internal error"}))
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, true, nil, nil, LabelConfig{})
+	defer metrics.Registry.Unregister(worker)
+	worker.zoneCache = mockZoneCache
+
+	worker.RefreshZoneData()
+	require.Equal(t, mockZoneCache, worker.zoneCache)
+}
+
+func TestCollectZoneFreshness(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, true, true, nil, nil, LabelConfig{})
+	defer metrics.Registry.Unregister(worker)
+
+	worker.zoneCache = map[string]*ns1_internal.Zone{
+		"foo.bar": {Zone: "foo.bar", LastUpdated: time.Now()},
+		"keep.me": {Zone: "keep.me", LastUpdated: time.Now().Add(-time.Hour), Stale: true},
+		"drop.me": {Zone: "drop.me"},
+	}
+	worker.publish()
+
+	expected := `
+# HELP ns1_zone_stale Whether the cached data for the labeled zone was carried forward from a previous refresh because the most recent refresh failed (1) or not (0).
+# TYPE ns1_zone_stale gauge
+ns1_zone_stale{zone="foo.bar"} 0
+ns1_zone_stale{zone="keep.me"} 1
+`
+	require.NoError(t, prom_testutil.CollectAndCompare(worker, strings.NewReader(expected), "ns1_zone_stale"))
+	require.Equal(t, 2, prom_testutil.CollectAndCount(worker, "ns1_zone_data_age_seconds"))
+}
+
+func TestFoldRecordQPS(t *testing.T) {
+	cache := []*ns1_internal.QPS{
+		{Value: 1, ZoneName: "foo.bar", RecordName: "a.foo.bar", RecordType: "A"},
+		{Value: 50, ZoneName: "foo.bar", RecordName: "b.foo.bar", RecordType: "A"},
+		{Value: 10, ZoneName: "foo.bar", RecordName: "c.foo.bar", RecordType: "TXT"},
+		{Value: 10, ZoneName: "foo.bar", RecordName: "c.foo.bar", RecordType: "A"},
+		{Value: 5, ZoneName: "keep.me", RecordName: "a.keep.me", RecordType: "A"},
+	}
+
+	tests := map[string]struct {
+		topN       int
+		minimum    float64
+		want       []*ns1_internal.QPS
+		wantFolded map[string]int
+	}{
+		"top_n": {topN: 2, want: []*ns1_internal.QPS{
+			{Value: 50, ZoneName: "foo.bar", RecordName: "b.foo.bar", RecordType: "A"},
+			{Value: 10, ZoneName: "foo.bar", RecordName: "c.foo.bar", RecordType: "A"},
+			{Value: 11, ZoneName: "foo.bar", RecordName: OtherRecordName},
+			{Value: 5, ZoneName: "keep.me", RecordName: "a.keep.me", RecordType: "A"},
+		}, wantFolded: map[string]int{"foo.bar": 2, "keep.me": 0}},
+		"minimum": {minimum: 10, want: []*ns1_internal.QPS{
+			{Value: 50, ZoneName: "foo.bar", RecordName: "b.foo.bar", RecordType: "A"},
+			{Value: 10, ZoneName: "foo.bar", RecordName: "c.foo.bar", RecordType: "A"},
+			{Value: 10, ZoneName: "foo.bar", RecordName: "c.foo.bar", RecordType: "TXT"},
+			{Value: 1, ZoneName: "foo.bar", RecordName: OtherRecordName},
+			{Value: 5, ZoneName: "keep.me", RecordName: OtherRecordName},
+		}, wantFolded: map[string]int{"foo.bar": 1, "keep.me": 1}},
+		"top_n_and_minimum": {topN: 1, minimum: 10, want: []*ns1_internal.QPS{
+			{Value: 50, ZoneName: "foo.bar", RecordName: "b.foo.bar", RecordType: "A"},
+			{Value: 21, ZoneName: "foo.bar", RecordName: OtherRecordName},
+			{Value: 5, ZoneName: "keep.me", RecordName: OtherRecordName},
+		}, wantFolded: map[string]int{"foo.bar": 3, "keep.me": 1}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := make([]*ns1_internal.QPS, len(cache))
+			for i, qps := range cache {
+				c := *qps
+				input[i] = &c
+			}
+
+			got, folded := foldRecordQPS(input, tc.topN, tc.minimum)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.wantFolded, folded)
+		})
+	}
+}
+
+func TestParseTagLabels(t *testing.T) {
+	tests := map[string]struct {
+		tagLabels []string
+		want      []TagLabel
+		wantErr   bool
+	}{
+		"empty":           {tagLabels: nil, want: nil},
+		"tag_only":        {tagLabels: []string{"team"}, want: []TagLabel{{Tag: "team", Label: "team"}}},
+		"tag_and_label":   {tagLabels: []string{"cost-center=cost_center"}, want: []TagLabel{{Tag: "cost-center", Label: "cost_center"}}},
+		"invalid_label":   {tagLabels: []string{"cost-center"}, wantErr: true},
+		"reserved_label":  {tagLabels: []string{"foo=__name__"}, wantErr: true},
+		"qps_label":       {tagLabels: []string{"zone=zone_name"}, wantErr: true},
+		"view_label":      {tagLabels: []string{"site=views"}, wantErr: true},
+		"duplicate_label": {tagLabels: []string{"team", "owner=team"}, wantErr: true},
+		"missing_tag":     {tagLabels: []string{"=team"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseTagLabels(tc.tagLabels)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRefreshQPSRecordDataTagLabels(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	zoneCache := map[string]*ns1_internal.Zone{
+		"foo.bar": {Zone: "foo.bar", Tags: map[string]string{"team": "dns", "service": "web"}, Records: []*ns1_internal.ZoneRecord{
+			{Domain: "test.foo.bar", Type: "A", Tags: map[string]string{"team": "edge"}},
+			{Domain: "test.foo.bar", Type: "AAAA"},
+		}},
+	}
+	for _, r := range zoneCache["foo.bar"].Records {
+		require.NoError(t, mock.AddTestCase(http.MethodGet, fmt.Sprintf("stats/qps/foo.bar/%s/%s", r.Domain, r.Type),
+			http.StatusOK, nil, nil, "", struct{ QPS float32 }{QPS: 10}),
+		)
+	}
+
+	// the registry remembers the label names of unregistered metrics, so
+	// use a separate registry for a worker with a different QPS desc
+	registry := metrics.Registry
+	metrics.Registry = prometheus.NewRegistry()
+	t.Cleanup(func() { metrics.Registry = registry })
+
+	tagLabels, err := ParseTagLabels([]string{"team", "service=owner_service", "env"})
+	require.NoError(t, err)
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, true, nil, nil, LabelConfig{TagLabels: tagLabels})
+	worker.zoneCache = zoneCache
+
+	worker.RefreshQPSRecordData()
+
+	expected := `
+# HELP ns1_stats_queries_per_second DNS queries per second for the labeled NS1 resource. Note that NS1 QPS metrics are time delayed, not real-time.
+# TYPE ns1_stats_queries_per_second gauge
+ns1_stats_queries_per_second{env="",owner_service="web",record_name="test.foo.bar",record_type="A",team="edge",zone_name="foo.bar"} 10
+ns1_stats_queries_per_second{env="",owner_service="web",record_name="test.foo.bar",record_type="AAAA",team="dns",zone_name="foo.bar"} 10
+`
+	require.NoError(t, prom_testutil.CollectAndCompare(worker, strings.NewReader(expected), "ns1_stats_queries_per_second"))
+}
+
+func TestRefreshQPSZoneDataViewLabels(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	// the networks and views of a zone are only exposed in the view labels,
+	// so the zone_name label stays the zone's name
+	zoneCache := map[string]*ns1_internal.Zone{
+		"foo.bar": {Zone: "foo.bar", NetworkIDs: []int{0, 5}, Views: []string{"external", "internal"}},
+	}
+	require.NoError(t, mock.AddTestCase(http.MethodGet, "stats/qps/foo.bar", http.StatusOK, nil, nil, "",
+		struct{ QPS float32 }{QPS: 10}),
+	)
+
+	registry := metrics.Registry
+	metrics.Registry = prometheus.NewRegistry()
+	t.Cleanup(func() { metrics.Registry = registry })
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), true, false, nil, nil, LabelConfig{ViewLabels: true})
+	worker.zoneCache = zoneCache
+
+	worker.RefreshQPSZoneData()
+
+	expected := `
+# HELP ns1_stats_queries_per_second DNS queries per second for the labeled NS1 resource. Note that NS1 QPS metrics are time delayed, not real-time.
+# TYPE ns1_stats_queries_per_second gauge
+ns1_stats_queries_per_second{networks="0,5",record_name="",record_type="",views="external,internal",zone_name="foo.bar"} 10
+`
+	require.NoError(t, prom_testutil.CollectAndCompare(worker, strings.NewReader(expected), "ns1_stats_queries_per_second"))
+}
+
+// staticBackend is a Backend that serves a fixed set of zones and account
+// activity, with a QPS of 1 for every zone and record. Records are identified
+// by their domain, and lookups of each record are counted.
+type staticBackend struct {
+	zones       []*dns.Zone
+	meta        map[string]*data.Meta
+	activity    []*account.Activity
+	recordCalls map[string]int
+}
+
+func (b *staticBackend) ListZones() ([]*dns.Zone, error) {
+	return b.zones, nil
+}
+
+func (b *staticBackend) ListViews() ([]*dns.View, error) {
+	return nil, nil
+}
+
+func (b *staticBackend) GetZone(zone string, _ bool) (*dns.Zone, error) {
+	for _, z := range b.zones {
+		if z.Zone == zone {
+			return z, nil
+		}
+	}
+
+	return nil, fmt.Errorf("zone %q not found", zone)
+}
+
+func (b *staticBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	if b.recordCalls != nil {
+		b.recordCalls[domain]++
+	}
+
+	return &dns.Record{ID: domain, Zone: zone, Domain: domain, Type: recordType, Meta: b.meta[domain]}, nil
+}
+
+func (b *staticBackend) GetQPS() (float32, error) {
+	return 1, nil
+}
+
+func (b *staticBackend) GetZoneQPS(_ string) (float32, error) {
+	return 1, nil
+}
+
+func (b *staticBackend) GetRecordQPS(_, _, _ string) (float32, error) {
+	return 1, nil
+}
+
+func (b *staticBackend) ListActivity(_ ...api.Param) ([]*account.Activity, error) {
+	return b.activity, nil
+}
+
+func TestRefreshQPSRecordDataMetaCache(t *testing.T) {
+	tests := map[string]struct {
+		activity  []*account.Activity
+		refreshes int
+		wantCalls map[string]int
+	}{
+		"no_activity": {
+			refreshes: 2,
+			wantCalls: map[string]int{"a.foo.bar": 1, "b.foo.bar": 1},
+		},
+		"other_activity": {
+			activity:  []*account.Activity{{ResourceType: "user", ResourceID: "a.foo.bar"}},
+			refreshes: 2,
+			wantCalls: map[string]int{"a.foo.bar": 1, "b.foo.bar": 1},
+		},
+		"record_activity": {
+			activity:  []*account.Activity{{ResourceType: "record", ResourceID: "a.foo.bar"}},
+			refreshes: 2,
+			wantCalls: map[string]int{"a.foo.bar": 2, "b.foo.bar": 1},
+		},
+		"zone_activity": {
+			activity:  []*account.Activity{{ResourceType: "datafeed", ResourceID: "feed"}},
+			refreshes: 2,
+			wantCalls: map[string]int{"a.foo.bar": 2, "b.foo.bar": 2},
+		},
+		// all records are looked up again every 10 polls, in case
+		// activity was missed
+		"polls": {
+			refreshes: 12,
+			wantCalls: map[string]int{"a.foo.bar": 2, "b.foo.bar": 2},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := &staticBackend{
+				meta: map[string]*data.Meta{
+					"a.foo.bar": {Note: "prod"},
+					"b.foo.bar": {Note: "dev"},
+				},
+				activity:    tc.activity,
+				recordCalls: make(map[string]int),
+			}
+
+			worker := NewWorker(mockLogger, backend, true, true, nil, nil, LabelConfig{})
+			defer metrics.Registry.Unregister(worker)
+			worker.RecordFilter = &ns1_internal.RecordFilter{Meta: map[string]*regexp.Regexp{"note": regexp.MustCompile("^prod$")}}
+			worker.zoneCache = map[string]*ns1_internal.Zone{
+				"foo.bar": {Zone: "foo.bar", Records: []*ns1_internal.ZoneRecord{
+					{Domain: "a.foo.bar", Type: "A"},
+					{Domain: "b.foo.bar", Type: "A"},
+				}},
+			}
+
+			for range tc.refreshes {
+				worker.RefreshQPSRecordData()
+				require.Equal(t, []*ns1_internal.QPS{
+					{Value: 1, ZoneName: "foo.bar", RecordName: "a.foo.bar", RecordType: "A"},
+				}, worker.qpsCache)
+			}
+			require.Equal(t, tc.wantCalls, backend.recordCalls)
+
+			// records that no longer exist are dropped from the cache
+			worker.zoneCache = map[string]*ns1_internal.Zone{"foo.bar": {Zone: "foo.bar"}}
+			worker.RefreshQPSRecordData()
+			require.Empty(t, worker.metaMatches)
+		})
+	}
+}
+
+func TestCollectDuringRefresh(t *testing.T) {
+	backend := &staticBackend{zones: []*dns.Zone{
+		{Zone: "foo.bar", Records: []*dns.ZoneRecord{{Domain: "test.foo.bar", Type: "A"}}},
+		{Zone: "keep.me", Records: []*dns.ZoneRecord{{Domain: "test.keep.me", Type: "A"}}},
+	}}
+
+	registry := metrics.Registry
+	metrics.Registry = prometheus.NewRegistry()
+	t.Cleanup(func() { metrics.Registry = registry })
+
+	worker := NewWorker(mockLogger, backend, true, true, nil, nil, LabelConfig{ViewLabels: true})
+	worker.RecordQPSTopN = 1
+
+	// collections run concurrently with refreshes, and must only see
+	// complete refreshes
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				worker.Refresh()
+			}
+		}
+	}()
+
+	for range 200 {
+		count := prom_testutil.CollectAndCount(worker, "ns1_stats_queries_per_second")
+		require.Contains(t, []int{0, 2}, count)
+	}
+	close(stop)
+	<-done
+
+	require.Equal(t, 2, prom_testutil.CollectAndCount(worker, "ns1_stats_queries_per_second"))
+	require.Equal(t, 2, prom_testutil.CollectAndCount(worker, "ns1_stats_folded_series"))
+}
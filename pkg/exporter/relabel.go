@@ -0,0 +1,91 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"go.yaml.in/yaml/v2"
+)
+
+// RelabelConfigFile is the format of the file loaded by LoadRelabelConfigs.
+type RelabelConfigFile struct {
+	// MetricRelabelConfigs are applied to each QPS series before it is
+	// exposed, using the same semantics as Prometheus's
+	// metric_relabel_configs.
+	MetricRelabelConfigs []*relabel.Config `yaml:"metric_relabel_configs"`
+}
+
+// LoadRelabelConfigs loads and validates relabel configs from a YAML file.
+func LoadRelabelConfigs(path string) ([]*relabel.Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relabel config file: %w", err)
+	}
+
+	return ParseRelabelConfigs(buf)
+}
+
+// ParseRelabelConfigs parses and validates relabel configs from YAML.
+func ParseRelabelConfigs(buf []byte) ([]*relabel.Config, error) {
+	var cfg RelabelConfigFile
+	if err := yaml.UnmarshalStrict(buf, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse relabel config: %w", err)
+	}
+
+	for i, rc := range cfg.MetricRelabelConfigs {
+		if rc == nil {
+			return nil, fmt.Errorf("empty relabel config at index %d", i)
+		}
+		if err := rc.Validate(model.LegacyValidation); err != nil {
+			return nil, fmt.Errorf("invalid relabel config at index %d: %w", i, err)
+		}
+	}
+
+	return cfg.MetricRelabelConfigs, nil
+}
+
+// relabelSeries applies relabel configs to the labels of a series with the
+// provided metric name. It returns the resulting label names and values,
+// without the metric name, and whether the series should be kept. The metric
+// name is available to relabel configs as __name__, but can't be changed, and
+// any other labels starting with __ are removed after relabeling.
+func relabelSeries(name string, names, values []string, cfgs []*relabel.Config) ([]string, []string, bool) {
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	lb.Set(model.MetricNameLabel, name)
+	for i, n := range names {
+		lb.Set(n, values[i])
+	}
+
+	if !relabel.ProcessBuilder(lb, cfgs...) {
+		return nil, nil, false
+	}
+
+	var outNames, outValues []string
+	lb.Labels().Range(func(l labels.Label) {
+		if strings.HasPrefix(l.Name, model.ReservedLabelPrefix) {
+			return
+		}
+		outNames = append(outNames, l.Name)
+		outValues = append(outValues, l.Value)
+	})
+
+	return outNames, outValues, true
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+func TestParseRelabelConfigs(t *testing.T) {
+	tests := map[string]struct {
+		config  string
+		wantLen int
+		wantErr bool
+	}{
+		"empty": {
+			config: "",
+		},
+		"valid": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_type]
+    regex: NS
+    action: drop
+  - target_label: env
+    replacement: prod
+`,
+			wantLen: 2,
+		},
+		"unknown_field": {
+			config: `
+metric_relabel_configs:
+  - source_label: [record_type]
+    action: drop
+`,
+			wantErr: true,
+		},
+		"invalid_action": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_type]
+    action: nope
+`,
+			wantErr: true,
+		},
+		"invalid_regex": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_type]
+    regex: "("
+    action: drop
+`,
+			wantErr: true,
+		},
+		"missing_target_label": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_type]
+    action: replace
+`,
+			wantErr: true,
+		},
+		"empty_entry": {
+			config: `
+metric_relabel_configs:
+  -
+`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfgs, err := ParseRelabelConfigs([]byte(tc.config))
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, cfgs, tc.wantLen)
+		})
+	}
+}
+
+func TestCollectRelabelQPS(t *testing.T) {
+	qpsCache := []*ns1_internal.QPS{
+		{Value: 10, ZoneName: "foo.bar", RecordName: "foo.bar", RecordType: "NS"},
+		{Value: 20, ZoneName: "foo.bar", RecordName: "test.foo.bar", RecordType: "A"},
+		{Value: 30, ZoneName: "foo.bar", RecordName: "test.foo.bar", RecordType: "AAAA"},
+		{Value: 40, ZoneName: "keep.me", RecordName: "test.keep.me", RecordType: "A"},
+	}
+
+	tests := map[string]struct {
+		config   string
+		expected string
+	}{
+		"drop": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_type]
+    regex: NS|AAAA
+    action: drop
+`,
+			expected: `
+ns1_stats_queries_per_second{record_name="test.foo.bar",record_type="A",zone_name="foo.bar"} 20
+ns1_stats_queries_per_second{record_name="test.keep.me",record_type="A",zone_name="keep.me"} 40
+`,
+		},
+		"keep": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [zone_name]
+    regex: keep\.me
+    action: keep
+`,
+			expected: `
+ns1_stats_queries_per_second{record_name="test.keep.me",record_type="A",zone_name="keep.me"} 40
+`,
+		},
+		"replace_and_static_label": {
+			config: `
+metric_relabel_configs:
+  - source_labels: [record_name, zone_name]
+    regex: (.*)\.(.*)\.(.*)\.(.*)
+    target_label: host
+    replacement: $1
+  - target_label: env
+    replacement: prod
+  - regex: record_name
+    action: labeldrop
+  - source_labels: [__name__]
+    regex: ns1_stats_queries_per_second
+    target_label: __tmp_unused
+    replacement: x
+`,
+			// records without a match for the host regex don't get the
+			// label, and the A/AAAA records are kept distinct by type
+			expected: `
+ns1_stats_queries_per_second{env="prod",record_type="NS",zone_name="foo.bar"} 10
+ns1_stats_queries_per_second{env="prod",host="test",record_type="A",zone_name="foo.bar"} 20
+ns1_stats_queries_per_second{env="prod",host="test",record_type="AAAA",zone_name="foo.bar"} 30
+ns1_stats_queries_per_second{env="prod",host="test",record_type="A",zone_name="keep.me"} 40
+`,
+		},
+		"duplicates": {
+			config: `
+metric_relabel_configs:
+  - regex: record_name|record_type
+    action: labeldrop
+`,
+			expected: `
+ns1_stats_queries_per_second{zone_name="foo.bar"} 10
+ns1_stats_queries_per_second{zone_name="keep.me"} 40
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the registry remembers the label names of unregistered
+			// metrics, so use a separate registry for each worker
+			registry := metrics.Registry
+			metrics.Registry = prometheus.NewRegistry()
+			t.Cleanup(func() { metrics.Registry = registry })
+
+			cfgs, err := ParseRelabelConfigs([]byte(tc.config))
+			require.NoError(t, err)
+
+			worker := NewWorker(mockLogger, nil, true, true, nil, nil, LabelConfig{RelabelConfigs: cfgs})
+			worker.qpsCache = qpsCache
+			worker.publish()
+
+			expected := `
+# HELP ns1_stats_queries_per_second DNS queries per second for the labeled NS1 resource. Note that NS1 QPS metrics are time delayed, not real-time.
+# TYPE ns1_stats_queries_per_second gauge
+` + tc.expected
+			require.NoError(t, prom_testutil.CollectAndCompare(worker, strings.NewReader(expected), "ns1_stats_queries_per_second"))
+		})
+	}
+}
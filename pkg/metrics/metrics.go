@@ -25,6 +25,16 @@ const (
 	metricNamespace = "ns1"
 )
 
+var (
+	// QPSMetricName is the name of the QPS metric.
+	QPSMetricName = prometheus.BuildFQName(metricNamespace, "stats", "queries_per_second")
+	// QPSLabels are the labels of the QPS metric, in order.
+	QPSLabels = []string{"zone_name", "record_name", "record_type"}
+	// QPSViewLabels are the optional labels of the QPS metric with the
+	// views and networks of the zone, in order.
+	QPSViewLabels = []string{"views", "networks"}
+)
+
 var (
 	once     sync.Once
 	Registry *prometheus.Registry
@@ -35,10 +45,29 @@ var (
 		"NS1 exporter build information",
 		[]string{"version", "build_date", "commit"}, nil,
 	)
-	MetricQPSDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(metricNamespace, "stats", "queries_per_second"),
-		"DNS queries per second for the labeled NS1 resource. Note that NS1 QPS metrics are time delayed, not real-time.",
-		[]string{"zone_name", "record_name", "record_type"}, nil,
+	// MetricQPSDesc is the default QPS metric description, without any
+	// labels for NS1 tags.
+	MetricQPSDesc = NewQPSDesc(QPSLabels)
+
+	MetricQPSFoldedSeriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "stats", "folded_series"),
+		"Number of record-level QPS series in the labeled zone that were folded into the record_name=\"__other__\" series by cardinality limits.",
+		[]string{"zone_name"}, nil,
+	)
+	MetricZoneDataAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "zone", "data_age_seconds"),
+		"Age in seconds of the cached data for the labeled zone, since it was last successfully retrieved from the NS1 API.",
+		[]string{"zone"}, nil,
+	)
+	MetricZoneStaleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "zone", "stale"),
+		"Whether the cached data for the labeled zone was carried forward from a previous refresh because the most recent refresh failed (1) or not (0).",
+		[]string{"zone"}, nil,
+	)
+	MetricStorageSnapshotAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "storage", "snapshot_age_seconds"),
+		"Age in seconds of the most recent on-disk snapshot of the labeled worker cache.",
+		[]string{"snapshot"}, nil,
 	)
 
 	// Metrics for operations of the exporter itself.
@@ -47,8 +76,73 @@ var (
 		Name:      "api_failures_total",
 		Help:      "Total number of failed NS1 API calls.",
 	})
+	MetricNS1ZoneFetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Name:      "zone_fetch_errors_total",
+		Help:      "Total number of failed attempts to retrieve the labeled zone's data from the NS1 API.",
+	}, []string{"zone"})
+	MetricNS1APIRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Name:      "api_retries_total",
+		Help:      "Total number of retried NS1 API calls, by method.",
+	}, []string{"method"})
+	MetricNS1APICircuitBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricNamespace,
+		Name:      "api_circuit_breaker_state",
+		Help:      "State of the NS1 API circuit breaker: closed (0), open (1) or half-open (2).",
+	})
+	MetricNS1APIKeyLoadTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricNamespace,
+		Name:      "api_key_load_timestamp_seconds",
+		Help:      "Unix timestamp at which the current NS1 API key was loaded.",
+	})
+	MetricNS1APIKeyRejected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricNamespace,
+		Name:      "api_key_rejected",
+		Help:      "Whether the current NS1 API key was rejected by the NS1 API (1) or not (0).",
+	})
+	MetricSDRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Name:      "sd_requests_total",
+		Help:      "Total number of HTTP service discovery requests, by endpoint and response status code.",
+	}, []string{"endpoint", "code"})
+	MetricSDResponseBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace,
+		Name:      "sd_response_bytes_total",
+		Help:      "Total number of response body bytes served for HTTP service discovery requests, by endpoint, after compression.",
+	}, []string{"endpoint"})
 )
 
+// NewQPSDesc creates the description of the QPS metric with the provided
+// labels, which must start with QPSLabels.
+func NewQPSDesc(labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		QPSMetricName,
+		"DNS queries per second for the labeled NS1 resource. Note that NS1 QPS metrics are time delayed, not real-time.",
+		labels, nil,
+	)
+}
+
+// NewZoneTagsInfoDesc creates the description of the zone tags info metric
+// with the provided labels.
+func NewZoneTagsInfoDesc(labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "zone", "tags_info"),
+		"Tags of the labeled NS1 zone, for joining onto other metrics. Tag keys are sanitized and prefixed with tag_.",
+		labels, nil,
+	)
+}
+
+// NewRecordTagsInfoDesc creates the description of the record tags info
+// metric with the provided labels.
+func NewRecordTagsInfoDesc(labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(metricNamespace, "record", "tags_info"),
+		"Tags and meta of the labeled NS1 record, for joining onto other metrics. Tag and meta keys are sanitized and prefixed with tag_ and meta_.",
+		labels, nil,
+	)
+}
+
 func init() {
 	once.Do(func() {
 		Registry = prometheus.NewRegistry()
@@ -60,6 +154,13 @@ func init() {
 			// register raw metrics -- let exporter worker register
 			// itself for collection of metrics from ns1 api
 			MetricExporterNS1APIFailures,
+			MetricNS1APIKeyLoadTimestamp,
+			MetricNS1APIKeyRejected,
+			MetricNS1APIRetries,
+			MetricNS1APICircuitBreakerState,
+			MetricNS1ZoneFetchErrors,
+			MetricSDRequests,
+			MetricSDResponseBytes,
 		)
 	})
 }
@@ -16,9 +16,15 @@ package ns1
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	api "gopkg.in/ns1/ns1-go.v2/rest"
@@ -27,12 +33,30 @@ import (
 	"github.com/tjhop/ns1_exporter/pkg/metrics"
 )
 
+// DefaultTimeout is the default timeout of HTTP requests to the NS1 API.
+const DefaultTimeout = 15 * time.Second
+
 type APIConfig struct {
-	Token         string
+	Token string
+	// Key is an optional APIKey that takes precedence over Token. It is
+	// read on every request, so that the key can be rotated at runtime.
+	Key           *APIKey
 	Concurrency   int
 	Endpoint      string
 	TLSSkipVerify bool
-	UserAgent     string
+	// TLSCAFile is an optional path to a PEM encoded CA bundle used to
+	// verify the NS1 API's certificate, such as for a private NS1
+	// deployment with an internal CA.
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are optional paths to a PEM encoded
+	// client certificate and key presented to the NS1 API.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ProxyURL is an optional HTTP proxy to use for requests to the NS1
+	// API. If empty, the standard proxy environment variables are used.
+	ProxyURL  string
+	Timeout   time.Duration
+	UserAgent string
 }
 
 // ZoneRecord is an internal struct that is essentially the same thing as a
@@ -42,7 +66,7 @@ type ZoneRecord struct {
 	Domain   string
 	ShortAns []string
 	Type     string
-	// TODO: add support for tags/local tags for DDI?
+	Tags     map[string]string
 }
 
 // Zone is an internal struct that is essentially the same thing as
@@ -50,8 +74,26 @@ type ZoneRecord struct {
 // care about right now.
 type Zone struct {
 	Zone       string
-	NetworkIDs []int // not used yet
+	NetworkIDs []int
 	Records    []*ZoneRecord
+	Tags       map[string]string
+	// Views are the names of the NS1 DNS views that contain the zone,
+	// when view lookup is enabled.
+	Views []string `json:",omitempty"`
+	// TTL, DNSSEC, Primary and Secondary are the zone's settings: its
+	// default TTL, whether DNSSEC is enabled, and whether it's a primary
+	// zone with outgoing zone transfers enabled, or a secondary zone that
+	// transfers its records from a primary elsewhere.
+	TTL       int  `json:",omitempty"`
+	DNSSEC    bool `json:",omitempty"`
+	Primary   bool `json:",omitempty"`
+	Secondary bool `json:",omitempty"`
+	// LastUpdated is the time the zone's data was last retrieved from the
+	// NS1 API. Stale is set when the most recent attempt to retrieve it
+	// failed, and the zone's data was carried forward from a previous
+	// refresh instead.
+	LastUpdated time.Time
+	Stale       bool
 }
 
 // QPS holds values related to QPS info from the NS1 API.
@@ -60,22 +102,37 @@ type QPS struct {
 	ZoneName   string
 	RecordName string
 	RecordType string
+	// Tags holds the NS1 tags of the zone/record that are exposed as
+	// metric labels, if any.
+	Tags map[string]string `json:",omitempty"`
 }
 
 // NewClient creates a new NS1 API client based on the provided config.
-func NewClient(config APIConfig) *api.Client {
-	httpClient := &http.Client{Timeout: time.Second * 15}
-	clientOpts := []func(*api.Client){api.SetAPIKey(config.Token), api.SetFollowPagination(true), api.SetUserAgent(config.UserAgent)}
+func NewClient(config APIConfig) (*api.Client, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
 
-	if config.Endpoint != "" {
-		clientOpts = append(clientOpts, api.SetEndpoint(config.Endpoint))
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
 	}
 
-	if config.TLSSkipVerify {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	var httpClient api.Doer = &http.Client{Timeout: timeout, Transport: transport}
+	token := config.Token
+	if config.Key != nil {
+		httpClient = &apiKeyDoer{doer: httpClient, key: config.Key}
+		token = config.Key.Get()
+	}
+	clientOpts := []func(*api.Client){api.SetAPIKey(token), api.SetFollowPagination(true), api.SetUserAgent(config.UserAgent)}
+
+	if config.Endpoint != "" {
+		endpoint, err := parseEndpoint(config.Endpoint)
+		if err != nil {
+			return nil, err
 		}
-		httpClient.Transport = tr
+		clientOpts = append(clientOpts, api.SetEndpoint(endpoint))
 	}
 
 	c := api.NewClient(httpClient, clientOpts...)
@@ -86,17 +143,98 @@ func NewClient(config APIConfig) *api.Client {
 		c.RateLimitStrategySleep()
 	}
 
-	return c
+	return c, nil
+}
+
+// parseEndpoint validates an NS1 API endpoint URL and ensures it ends with a
+// trailing slash, since the NS1 Go SDK resolves request paths relative to it.
+func parseEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid NS1 API endpoint: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid NS1 API endpoint %q: scheme must be http or https", endpoint)
+	}
+
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid NS1 API endpoint %q: missing host", endpoint)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u.String(), nil
+}
+
+// newTransport creates the HTTP transport used by the NS1 API client, with
+// the TLS and proxy settings from the provided config applied on top of the
+// defaults of http.DefaultTransport.
+func newTransport(config APIConfig) (*http.Transport, error) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected type for http.DefaultTransport")
+	}
+	transport = transport.Clone()
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.TLSSkipVerify, //nolint:gosec // opt-in via flag, for private deployments
+	}
+
+	if config.TLSCAFile != "" {
+		caCert, err := os.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid PEM certificates found in CA file %s", config.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case config.TLSCertFile != "" && config.TLSKeyFile != "":
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case config.TLSCertFile != "" || config.TLSKeyFile != "":
+		return nil, errors.New("both a client certificate and key must be provided")
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
 }
 
-func RefreshZoneData(logger *slog.Logger, c *api.Client, getRecords bool, zoneBlacklist, zoneWhitelist *regexp.Regexp) map[string]*Zone {
+// RefreshZoneData lists the zones in the account from the NS1 API, filters
+// them against the provided blacklist/whitelist and view options, and
+// optionally gets the records of each zone. Zones are keyed by name, and
+// zones with the same name on multiple networks are merged into a single
+// zone. If the zones can't be listed, an error is returned instead of an
+// empty map, so that callers can keep their previous data. If the records of
+// a single zone can't be retrieved, the zone's entry in the provided previous
+// zone data, if any, is carried forward and marked stale.
+func RefreshZoneData(logger *slog.Logger, c Backend, getRecords bool, zoneBlacklist, zoneWhitelist *regexp.Regexp, views ViewOptions, previous map[string]*Zone) (map[string]*Zone, error) {
 	zMap := make(map[string]*Zone)
 
-	zones, _, err := c.Zones.List()
+	zones, err := c.ListZones()
 	if err != nil {
-		logger.Error("Failed to list zones from NS1 API", "err", err, "worker", "exporter")
 		metrics.MetricExporterNS1APIFailures.Inc()
-		return zMap
+		return nil, fmt.Errorf("failed to list zones from NS1 API: %w", err)
 	}
 
 	// check listed zones against any provided blacklist and remove ones that we don't care about
@@ -130,13 +268,67 @@ func RefreshZoneData(logger *slog.Logger, c *api.Client, getRecords bool, zoneBl
 		zones = filteredZones
 	}
 
+	// look up the views of each zone, if enabled, and check listed zones
+	// against any provided view/network whitelists
+	var viewList []*dns.View
+	if views.Enabled {
+		viewList, err = c.ListViews()
+		if err != nil {
+			metrics.MetricExporterNS1APIFailures.Inc()
+			return nil, fmt.Errorf("failed to list views from NS1 API: %w", err)
+		}
+	}
+
+	// the NS1 API looks up zones by name, so zones with the same name on
+	// multiple networks can't be told apart, and are merged into a single
+	// zone that is served on all of their networks, in all of their views
+	zoneNetworks := make(map[string][]int, len(zones))
+	zoneViewNames := make(map[string][]string, len(zones))
+	var filteredZones []*dns.Zone
+	for _, z := range zones {
+		if !views.matchNetworks(z.NetworkIDs) {
+			logger.Debug("skipping zone because it isn't served on a whitelisted network", "zone", z.Zone, "networks", JoinNetworkIDs(z.NetworkIDs))
+			continue
+		}
+
+		zViews := zoneViews(viewList, z.Zone, z.NetworkIDs)
+		if views.Enabled && !views.matchViews(zViews) {
+			logger.Debug("skipping zone because it isn't in a view matching whitelist regex", "zone", z.Zone, "views", strings.Join(zViews, ","))
+			continue
+		}
+
+		networkIDs, seen := zoneNetworks[z.Zone]
+		if !seen {
+			zoneNetworks[z.Zone] = z.NetworkIDs
+			zoneViewNames[z.Zone] = zViews
+			filteredZones = append(filteredZones, z)
+			continue
+		}
+
+		zoneNetworks[z.Zone] = mergeNetworkIDs(networkIDs, z.NetworkIDs)
+		zoneViewNames[z.Zone] = mergeViews(zoneViewNames[z.Zone], zViews)
+	}
+	zones = filteredZones
+
 	// iterate over listed zones and get details for each
 	switch {
 	case getRecords:
 		for _, z := range zones {
-			zoneDataRaw, _, err := c.Zones.Get(z.Zone, true)
+			zoneDataRaw, err := c.GetZone(z.Zone, true)
 			if err != nil {
-				logger.Error("Failed to get zone data from NS1 API", "err", err, "worker", "exporter", "zone_name", z.Zone)
+				metrics.MetricExporterNS1APIFailures.Inc()
+				metrics.MetricNS1ZoneFetchErrors.WithLabelValues(z.Zone).Inc()
+
+				prev, ok := previous[z.Zone]
+				if !ok || prev.LastUpdated.IsZero() {
+					logger.Error("Failed to get zone data from NS1 API, no previous data to keep", "err", err, "zone_name", z.Zone)
+					continue
+				}
+
+				logger.Error("Failed to get zone data from NS1 API, keeping previous zone data", "err", err, "zone_name", z.Zone, "last_updated", prev.LastUpdated)
+				stale := *prev
+				stale.Stale = true
+				zMap[z.Zone] = &stale
 				continue
 			}
 
@@ -147,15 +339,23 @@ func RefreshZoneData(logger *slog.Logger, c *api.Client, getRecords bool, zoneBl
 					Domain:   r.Domain,
 					ShortAns: r.ShortAns,
 					Type:     r.Type,
+					Tags:     r.Tags,
 				}
 
 				recordData = append(recordData, record)
 			}
 
 			zoneData := &Zone{
-				Zone:       z.Zone,
-				NetworkIDs: zoneDataRaw.NetworkIDs,
-				Records:    recordData,
+				Zone:        z.Zone,
+				NetworkIDs:  zoneNetworks[z.Zone],
+				Records:     recordData,
+				Tags:        zoneDataRaw.Tags,
+				Views:       zoneViewNames[z.Zone],
+				TTL:         zoneDataRaw.TTL,
+				DNSSEC:      zoneDataRaw.DNSSEC != nil && *zoneDataRaw.DNSSEC,
+				Primary:     zoneDataRaw.Primary != nil && zoneDataRaw.Primary.Enabled,
+				Secondary:   zoneDataRaw.Secondary != nil && zoneDataRaw.Secondary.Enabled,
+				LastUpdated: time.Now(),
 			}
 
 			// insert zone into new worker "cache" map
@@ -170,5 +370,5 @@ func RefreshZoneData(logger *slog.Logger, c *api.Client, getRecords bool, zoneBl
 		}
 	}
 
-	return zMap
+	return zMap, nil
 }
@@ -15,16 +15,25 @@
 package ns1
 
 import (
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promslog"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/ns1/ns1-go.v2/mockns1"
 	api "gopkg.in/ns1/ns1-go.v2/rest"
 	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
 )
 
 var (
@@ -40,6 +49,7 @@ func TestRefreshZoneData(t *testing.T) {
 	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
 	require.NoError(t, err)
 
+	dnssec := true
 	tests := map[string]struct {
 		zoneEnabled   bool
 		recordEnabled bool
@@ -54,16 +64,16 @@ func TestRefreshZoneData(t *testing.T) {
 			"drop.me": {},
 		}, expectedLen: 3},
 		"recordsEnabled": {recordEnabled: true, zoneEnabled: false, zoneBlacklist: nil, zoneWhitelist: nil, want: map[string]*Zone{
-			"foo.bar": {Zone: "foo.bar", Records: []*ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}}},
-			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}},
+			"foo.bar": {Zone: "foo.bar", Records: []*ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}}, TTL: 3600, DNSSEC: true, Primary: true},
+			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}, Secondary: true},
 			"drop.me": {Zone: "drop.me", Records: []*ZoneRecord{{Domain: "test.drop.me", ShortAns: []string{"5.6.7.8"}, Type: "A"}}},
 		}, expectedLen: 3},
 		"blacklist": {recordEnabled: true, zoneEnabled: false, zoneBlacklist: regexp.MustCompile("drop.+"), zoneWhitelist: nil, want: map[string]*Zone{
-			"foo.bar": {Zone: "foo.bar", Records: []*ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}}},
-			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}},
+			"foo.bar": {Zone: "foo.bar", Records: []*ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}}, TTL: 3600, DNSSEC: true, Primary: true},
+			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}, Secondary: true},
 		}, expectedLen: 2},
 		"whitelist": {recordEnabled: true, zoneEnabled: false, zoneBlacklist: nil, zoneWhitelist: regexp.MustCompile("keep.+"), want: map[string]*Zone{
-			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}},
+			"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}, Secondary: true},
 		}, expectedLen: 1},
 	}
 
@@ -80,12 +90,18 @@ func TestRefreshZoneData(t *testing.T) {
 			getRecords := tc.recordEnabled || tc.zoneEnabled
 
 			require.NoError(t, mock.AddZoneGetTestCase("foo.bar", nil, nil,
-				&dns.Zone{Zone: "foo.bar", Records: []*dns.ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}}},
+				&dns.Zone{
+					Zone: "foo.bar", Records: []*dns.ZoneRecord{{Domain: "test.foo.bar", ShortAns: []string{"dead::beef"}, Type: "AAAA"}},
+					TTL: 3600, DNSSEC: &dnssec, Primary: &dns.ZonePrimary{Enabled: true},
+				},
 				getRecords,
 			))
 
 			require.NoError(t, mock.AddZoneGetTestCase("keep.me", nil, nil,
-				&dns.Zone{Zone: "keep.me", Records: []*dns.ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}}},
+				&dns.Zone{
+					Zone: "keep.me", Records: []*dns.ZoneRecord{{Domain: "test.keep.me", ShortAns: []string{"1.2.3.4"}, Type: "A"}},
+					Secondary: &dns.ZoneSecondary{Enabled: true, PrimaryIP: "192.0.2.1"},
+				},
 				getRecords,
 			))
 
@@ -94,7 +110,13 @@ func TestRefreshZoneData(t *testing.T) {
 				getRecords,
 			))
 
-			got := RefreshZoneData(mockLogger, mockClient, getRecords, tc.zoneBlacklist, tc.zoneWhitelist)
+			got, err := RefreshZoneData(mockLogger, NewAPIBackend(mockClient), getRecords, tc.zoneBlacklist, tc.zoneWhitelist, ViewOptions{}, nil)
+			require.NoError(t, err)
+			for _, zone := range got {
+				require.False(t, zone.Stale)
+				require.Equal(t, getRecords, !zone.LastUpdated.IsZero())
+				zone.LastUpdated = time.Time{}
+			}
 			require.Equal(t, tc.want, got)
 			require.Len(t, got, tc.expectedLen)
 			for _, zone := range got {
@@ -111,3 +133,166 @@ func TestRefreshZoneData(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshZoneDataListFailure(t *testing.T) {
+	mock := newMockBackend()
+	mock.fail = true
+
+	got, err := RefreshZoneData(mockLogger, mock, true, nil, nil, ViewOptions{}, nil)
+	require.ErrorIs(t, err, errMockBackend)
+	require.Nil(t, got)
+}
+
+// zoneFailingBackend is a Backend that fails to get a single zone.
+type zoneFailingBackend struct {
+	*mockBackend
+	zones    []string
+	failZone string
+}
+
+func (b *zoneFailingBackend) ListZones() ([]*dns.Zone, error) {
+	zones := make([]*dns.Zone, len(b.zones))
+	for i, z := range b.zones {
+		zones[i] = &dns.Zone{Zone: z}
+	}
+
+	return zones, nil
+}
+
+func (b *zoneFailingBackend) GetZone(zone string, _ bool) (*dns.Zone, error) {
+	if zone == b.failZone {
+		return nil, errMockBackend
+	}
+
+	return &dns.Zone{Zone: zone, Records: []*dns.ZoneRecord{{Domain: "new." + zone, Type: "A"}}}, nil
+}
+
+func TestRefreshZoneDataZoneFailure(t *testing.T) {
+	lastUpdated := time.Now().Add(-time.Hour)
+	previous := map[string]*Zone{
+		"foo.bar": {Zone: "foo.bar", Records: []*ZoneRecord{{Domain: "old.foo.bar", Type: "A"}}, LastUpdated: lastUpdated},
+		"keep.me": {Zone: "keep.me", Records: []*ZoneRecord{{Domain: "old.keep.me", Type: "A"}}, LastUpdated: lastUpdated},
+	}
+	backend := &zoneFailingBackend{mockBackend: newMockBackend(), zones: []string{"foo.bar", "keep.me", "new.zone"}}
+
+	tests := map[string]struct {
+		failZone  string
+		wantStale map[string]bool
+	}{
+		"no_failure":        {wantStale: map[string]bool{"foo.bar": false, "keep.me": false, "new.zone": false}},
+		"carried_forward":   {failZone: "keep.me", wantStale: map[string]bool{"foo.bar": false, "keep.me": true, "new.zone": false}},
+		"no_previous_entry": {failZone: "new.zone", wantStale: map[string]bool{"foo.bar": false, "keep.me": false}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend.failZone = tc.failZone
+			errorsBefore := testutil.ToFloat64(metrics.MetricNS1ZoneFetchErrors.WithLabelValues(tc.failZone))
+
+			got, err := RefreshZoneData(mockLogger, backend, true, nil, nil, ViewOptions{}, previous)
+			require.NoError(t, err)
+			require.Len(t, got, len(tc.wantStale))
+
+			for zName, wantStale := range tc.wantStale {
+				require.Equal(t, wantStale, got[zName].Stale, zName)
+				if wantStale {
+					require.Equal(t, previous[zName].Records, got[zName].Records)
+					require.Equal(t, lastUpdated, got[zName].LastUpdated)
+					continue
+				}
+				require.Equal(t, "new."+zName, got[zName].Records[0].Domain)
+			}
+
+			if tc.failZone != "" {
+				require.InDelta(t, errorsBefore+1, testutil.ToFloat64(metrics.MetricNS1ZoneFetchErrors.WithLabelValues(tc.failZone)), 0)
+			}
+
+			// the previous data must not be modified
+			require.False(t, previous["keep.me"].Stale)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := map[string]struct {
+		config       APIConfig
+		wantEndpoint string
+		wantErr      bool
+	}{
+		"default": {
+			config:       APIConfig{Token: "mockAPIKey"},
+			wantEndpoint: "https://api.nsone.net/v1/",
+		},
+		"endpoint_trailing_slash": {
+			config:       APIConfig{Token: "mockAPIKey", Endpoint: "https://ddi.example.com/v1"},
+			wantEndpoint: "https://ddi.example.com/v1/",
+		},
+		"endpoint_invalid_scheme": {
+			config:  APIConfig{Token: "mockAPIKey", Endpoint: "ftp://ddi.example.com/v1/"},
+			wantErr: true,
+		},
+		"endpoint_missing_host": {
+			config:  APIConfig{Token: "mockAPIKey", Endpoint: "https:///v1/"},
+			wantErr: true,
+		},
+		"missing_ca_file": {
+			config:  APIConfig{Token: "mockAPIKey", TLSCAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: true,
+		},
+		"cert_without_key": {
+			config:  APIConfig{Token: "mockAPIKey", TLSCertFile: "client.pem"},
+			wantErr: true,
+		},
+		"invalid_proxy_url": {
+			config:  APIConfig{Token: "mockAPIKey", ProxyURL: "://proxy"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tc.config)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.wantEndpoint, c.Endpoint.String())
+		})
+	}
+}
+
+func TestNewClientTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(ts.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	tests := map[string]struct {
+		config  APIConfig
+		wantErr bool
+	}{
+		"unknown_ca":  {config: APIConfig{Token: "mockAPIKey", Endpoint: ts.URL + "/v1/"}, wantErr: true},
+		"ca_file":     {config: APIConfig{Token: "mockAPIKey", Endpoint: ts.URL + "/v1/", TLSCAFile: caFile}},
+		"skip_verify": {config: APIConfig{Token: "mockAPIKey", Endpoint: ts.URL + "/v1/", TLSSkipVerify: true}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tc.config)
+			require.NoError(t, err)
+
+			_, _, err = c.Zones.List()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
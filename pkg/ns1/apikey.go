@@ -0,0 +1,207 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+const headerAPIKey = "X-NSONE-Key"
+
+// APIKey holds the NS1 API key used by a client. The key can be swapped at
+// runtime, such as when it is rotated, without recreating the client.
+type APIKey struct {
+	key      atomic.Pointer[string]
+	rejected atomic.Bool
+	logger   *slog.Logger
+	// mu serializes key swaps with updates of the rejected state, so that
+	// responses to requests sent with a previous key can't change the
+	// rejected state of the current key
+	mu sync.Mutex
+}
+
+// NewAPIKey creates a new APIKey holding the provided key.
+func NewAPIKey(logger *slog.Logger, key string) *APIKey {
+	k := &APIKey{
+		logger: logger.With("component", "api_key"),
+	}
+	k.Set(key)
+
+	return k
+}
+
+// Get returns the current API key.
+func (k *APIKey) Get() string {
+	return *k.key.Load()
+}
+
+// load returns a pointer to the current API key, which identifies the key
+// even if the same key is set again later.
+func (k *APIKey) load() *string {
+	return k.key.Load()
+}
+
+// Set replaces the current API key, and clears the rejected state of the
+// previous key.
+func (k *APIKey) Set(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.key.Store(&key)
+	k.rejected.Store(false)
+	metrics.MetricNS1APIKeyRejected.Set(0)
+	metrics.MetricNS1APIKeyLoadTimestamp.SetToCurrentTime()
+}
+
+// Rejected returns whether the NS1 API rejected the current API key on the
+// most recent request.
+func (k *APIKey) Rejected() bool {
+	return k.rejected.Load()
+}
+
+// observe updates the rejected state of the current API key based on the
+// status code of an NS1 API response to a request sent with the provided key.
+// Responses to requests sent with a key that has since been replaced are
+// ignored.
+func (k *APIKey) observe(sent *string, statusCode int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.key.Load() != sent {
+		return
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		if !k.rejected.Swap(true) {
+			k.logger.Error("NS1 API key was rejected by the NS1 API, requests will fail until a valid key is loaded")
+		}
+		metrics.MetricNS1APIKeyRejected.Set(1)
+	case statusCode < http.StatusBadRequest:
+		if k.rejected.Swap(false) {
+			k.logger.Info("NS1 API key accepted by the NS1 API")
+		}
+		metrics.MetricNS1APIKeyRejected.Set(0)
+	}
+}
+
+// apiKeyDoer is an api.Doer that sets the current API key on each request,
+// so that key rotations take effect on the next request made by the client.
+type apiKeyDoer struct {
+	doer api.Doer
+	key  *APIKey
+}
+
+// Do implements the api.Doer interface.
+func (d *apiKeyDoer) Do(req *http.Request) (*http.Response, error) {
+	key := d.key.load()
+	req.Header.Set(headerAPIKey, *key)
+
+	resp, err := d.doer.Do(req)
+	if err == nil {
+		d.key.observe(key, resp.StatusCode)
+	}
+
+	return resp, err
+}
+
+// APIKeyFile loads an NS1 API key from a file and reloads it when the file's
+// contents change.
+type APIKeyFile struct {
+	path   string
+	key    *APIKey
+	logger *slog.Logger
+}
+
+// NewAPIKeyFile creates a new APIKeyFile, loading the initial API key from the
+// file at the provided path.
+func NewAPIKeyFile(logger *slog.Logger, path string) (*APIKeyFile, error) {
+	key, err := readAPIKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyFile{
+		path:   path,
+		key:    NewAPIKey(logger, key),
+		logger: logger.With("component", "api_key_file"),
+	}, nil
+}
+
+func readAPIKeyFile(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	key := strings.TrimSpace(string(buf))
+	if key == "" {
+		return "", errors.New("API key file is empty")
+	}
+
+	return key, nil
+}
+
+// Key returns the APIKey loaded from the file.
+func (f *APIKeyFile) Key() *APIKey {
+	return f.key
+}
+
+// Reload reads the API key file and swaps in the key if it has changed. If
+// the file can't be read or is empty, the current key is kept.
+func (f *APIKeyFile) Reload() error {
+	key, err := readAPIKeyFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	if key == f.key.Get() {
+		return nil
+	}
+
+	f.key.Set(key)
+	f.logger.Info("Loaded new NS1 API key from file", "path", f.path)
+
+	return nil
+}
+
+// Watch reloads the API key file on every interval until cancel is closed.
+func (f *APIKeyFile) Watch(interval time.Duration, cancel <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Reload(); err != nil {
+				f.logger.Error("Failed to reload NS1 API key file, keeping current key", "err", err, "path", f.path)
+			}
+		case <-cancel:
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+func TestAPIKeyFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikey")
+	require.NoError(t, os.WriteFile(path, []byte("firstKey\n"), 0o600))
+
+	keyFile, err := NewAPIKeyFile(mockLogger, path)
+	require.NoError(t, err)
+	require.Equal(t, "firstKey", keyFile.Key().Get())
+
+	require.NoError(t, os.WriteFile(path, []byte("secondKey\n"), 0o600))
+	require.NoError(t, keyFile.Reload())
+	require.Equal(t, "secondKey", keyFile.Key().Get())
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0o600))
+	require.Error(t, keyFile.Reload())
+	require.Equal(t, "secondKey", keyFile.Key().Get())
+
+	require.NoError(t, os.Remove(path))
+	require.Error(t, keyFile.Reload())
+	require.Equal(t, "secondKey", keyFile.Key().Get())
+
+	_, err = NewAPIKeyFile(mockLogger, path)
+	require.Error(t, err)
+}
+
+func TestAPIKeyRotation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerAPIKey) != "validKey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "Unauthorized"}`))
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(ts.Close)
+
+	key := NewAPIKey(mockLogger, "invalidKey")
+	c, err := NewClient(APIConfig{Key: key, Endpoint: ts.URL + "/v1/"})
+	require.NoError(t, err)
+
+	_, _, err = c.Zones.List()
+	require.Error(t, err)
+	require.True(t, key.Rejected())
+	require.InDelta(t, 1, testutil.ToFloat64(metrics.MetricNS1APIKeyRejected), 0)
+
+	key.Set("validKey")
+	require.False(t, key.Rejected())
+	require.InDelta(t, 0, testutil.ToFloat64(metrics.MetricNS1APIKeyRejected), 0)
+
+	_, _, err = c.Zones.List()
+	require.NoError(t, err)
+	require.False(t, key.Rejected())
+}
+
+func TestAPIKeyRotationInFlight(t *testing.T) {
+	received := make(chan struct{})
+	rotated := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerAPIKey) != "validKey" {
+			// hold the response until the key was rotated
+			close(received)
+			<-rotated
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "Unauthorized"}`))
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(ts.Close)
+
+	key := NewAPIKey(mockLogger, "invalidKey")
+	c, err := NewClient(APIConfig{Key: key, Endpoint: ts.URL + "/v1/"})
+	require.NoError(t, err)
+
+	go func() {
+		<-received
+		key.Set("validKey")
+		close(rotated)
+	}()
+
+	// the rejection of the previous key doesn't mark the new key rejected
+	_, _, err = c.Zones.List()
+	require.Error(t, err)
+	require.False(t, key.Rejected())
+	require.InDelta(t, 0, testutil.ToFloat64(metrics.MetricNS1APIKeyRejected), 0)
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+// Names of the Backend methods, used to identify calls when recording them.
+const (
+	MethodListZones    = "ListZones"
+	MethodListViews    = "ListViews"
+	MethodGetZone      = "GetZone"
+	MethodGetRecord    = "GetRecord"
+	MethodGetQPS       = "GetQPS"
+	MethodGetZoneQPS   = "GetZoneQPS"
+	MethodGetRecordQPS = "GetRecordQPS"
+	MethodListActivity = "ListActivity"
+)
+
+// IsZoneActivity returns whether account activity can affect the data of zones
+// or records, such as changes to records or to the data feeds that update the
+// status of their answers.
+func IsZoneActivity(a *account.Activity) bool {
+	switch a.ResourceType {
+	case "dns_zone", "record", "notify_list", "datasource", "datafeed", "job":
+		return true
+	default:
+		return false
+	}
+}
+
+// Backend is the subset of the NS1 API used by the exporter and service
+// discovery workers. The NS1 Go SDK client is one implementation (see
+// APIBackend), and other implementations can wrap a Backend to add behavior
+// such as caching or rate limiting.
+type Backend interface {
+	// ListZones lists all zones on the account.
+	ListZones() ([]*dns.Zone, error)
+	// ListViews lists all DNS views on the account.
+	ListViews() ([]*dns.View, error)
+	// GetZone gets the details of a single zone, optionally including
+	// its records.
+	GetZone(zone string, records bool) (*dns.Zone, error)
+	// GetRecord gets the full details of a single record.
+	GetRecord(zone, domain, recordType string) (*dns.Record, error)
+	// GetQPS gets account-level QPS stats.
+	GetQPS() (float32, error)
+	// GetZoneQPS gets zone-level QPS stats.
+	GetZoneQPS(zone string) (float32, error)
+	// GetRecordQPS gets record-level QPS stats.
+	GetRecordQPS(zone, domain, recordType string) (float32, error)
+	// ListActivity lists account activity, filtered by the provided params.
+	ListActivity(params ...api.Param) ([]*account.Activity, error)
+}
+
+// APIBackend is a Backend that queries the NS1 API using the NS1 Go SDK.
+type APIBackend struct {
+	client *api.Client
+}
+
+// NewAPIBackend creates a new Backend from an NS1 Go SDK client.
+func NewAPIBackend(client *api.Client) *APIBackend {
+	return &APIBackend{client: client}
+}
+
+// ListZones implements the Backend interface.
+func (b *APIBackend) ListZones() ([]*dns.Zone, error) {
+	zones, _, err := b.client.Zones.List()
+	return zones, err
+}
+
+// ListViews implements the Backend interface.
+func (b *APIBackend) ListViews() ([]*dns.View, error) {
+	views, _, err := b.client.View.List()
+	return views, err
+}
+
+// GetZone implements the Backend interface.
+func (b *APIBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	z, _, err := b.client.Zones.Get(zone, records)
+	return z, err
+}
+
+// GetRecord implements the Backend interface.
+func (b *APIBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	r, _, err := b.client.Records.Get(zone, domain, recordType)
+	return r, err
+}
+
+// GetQPS implements the Backend interface.
+func (b *APIBackend) GetQPS() (float32, error) {
+	qps, _, err := b.client.Stats.GetQPS()
+	return qps, err
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *APIBackend) GetZoneQPS(zone string) (float32, error) {
+	qps, _, err := b.client.Stats.GetZoneQPS(zone)
+	return qps, err
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *APIBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	qps, _, err := b.client.Stats.GetRecordQPS(zone, domain, recordType)
+	return qps, err
+}
+
+// ListActivity implements the Backend interface.
+func (b *APIBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	activity, _, err := b.client.Activity.List(params...)
+	return activity, err
+}
@@ -0,0 +1,376 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// CachingBackend is a Backend that caches successful zone and record lookups
+// for a fixed TTL, so that multiple workers refreshing the same data only
+// query the NS1 API once. QPS stats and account activity are never cached, and
+// cached lookups that listed account activity reports as changed are dropped
+// before the TTL expires.
+//
+// Cached zones and records are shared between callers, so callers must not
+// modify them.
+type CachingBackend struct {
+	Backend
+
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	lastPrune time.Time
+}
+
+// NewCachingBackend wraps a Backend with a cache of zone and record lookups.
+func NewCachingBackend(backend Backend, ttl time.Duration) *CachingBackend {
+	return &CachingBackend{
+		Backend:   backend,
+		ttl:       ttl,
+		entries:   make(map[string]cacheEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+func (b *CachingBackend) get(key string) (any, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+func (b *CachingBackend) set(key string, value any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.entries[key] = cacheEntry{value: value, expires: now.Add(b.ttl)}
+
+	// periodically drop expired entries so that zones and records deleted
+	// from the account don't stay in memory forever
+	if now.Sub(b.lastPrune) > b.ttl {
+		for k, v := range b.entries {
+			if now.After(v.expires) {
+				delete(b.entries, k)
+			}
+		}
+		b.lastPrune = now
+	}
+}
+
+func cached[T any](b *CachingBackend, key string, fetch func() (T, error)) (T, error) {
+	if v, ok := b.get(key); ok {
+		if value, ok := v.(T); ok {
+			return value, nil
+		}
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+
+	b.set(key, value)
+	return value, nil
+}
+
+// ListZones implements the Backend interface.
+func (b *CachingBackend) ListZones() ([]*dns.Zone, error) {
+	return cached(b, MethodListZones, b.Backend.ListZones)
+}
+
+// ListViews implements the Backend interface.
+func (b *CachingBackend) ListViews() ([]*dns.View, error) {
+	return cached(b, MethodListViews, b.Backend.ListViews)
+}
+
+// GetZone implements the Backend interface.
+func (b *CachingBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	return cached(b, callKey(MethodGetZone, zone, strconv.FormatBool(records)), func() (*dns.Zone, error) {
+		return b.Backend.GetZone(zone, records)
+	})
+}
+
+// GetRecord implements the Backend interface.
+func (b *CachingBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return cached(b, callKey(MethodGetRecord, zone, domain, recordType), func() (*dns.Record, error) {
+		return b.Backend.GetRecord(zone, domain, recordType)
+	})
+}
+
+// ListActivity implements the Backend interface. Cached lookups that the listed
+// activity can affect are dropped, so that callers refreshing their data
+// because of the activity don't get stale data from the cache. Record activity
+// only drops the changed record, if it's cached, and zone lookups, which list
+// the zone's records. Any other zone activity drops all cached lookups.
+func (b *CachingBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	activity, err := b.Backend.ListActivity(params...)
+	if err != nil {
+		return activity, err
+	}
+
+	b.invalidate(activity)
+	return activity, nil
+}
+
+func (b *CachingBackend) invalidate(activity []*account.Activity) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, a := range activity {
+		if !IsZoneActivity(a) {
+			continue
+		}
+
+		if a.ResourceType != "record" {
+			clear(b.entries)
+			return
+		}
+
+		for key, entry := range b.entries {
+			switch value := entry.value.(type) {
+			case *dns.Zone:
+				delete(b.entries, key)
+			case *dns.Record:
+				if value != nil && value.ID == a.ResourceID {
+					delete(b.entries, key)
+				}
+			}
+		}
+	}
+}
+
+// RateLimitedBackend is a Backend that limits the rate of requests made to
+// the wrapped Backend, independent of the rate limiting strategy of the NS1
+// Go SDK.
+type RateLimitedBackend struct {
+	backend Backend
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedBackend wraps a Backend so that at most requestsPerSecond
+// calls are made to it per second.
+func NewRateLimitedBackend(backend Backend, requestsPerSecond float64) *RateLimitedBackend {
+	burst := max(int(math.Ceil(requestsPerSecond)), 1)
+
+	return &RateLimitedBackend{
+		backend: backend,
+		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), burst),
+	}
+}
+
+func (b *RateLimitedBackend) wait() {
+	// a background context never gets canceled, so Wait() only errors if
+	// the burst is misconfigured, which NewRateLimitedBackend prevents
+	_ = b.limiter.Wait(context.Background())
+}
+
+// ListZones implements the Backend interface.
+func (b *RateLimitedBackend) ListZones() ([]*dns.Zone, error) {
+	b.wait()
+	return b.backend.ListZones()
+}
+
+// ListViews implements the Backend interface.
+func (b *RateLimitedBackend) ListViews() ([]*dns.View, error) {
+	b.wait()
+	return b.backend.ListViews()
+}
+
+// GetZone implements the Backend interface.
+func (b *RateLimitedBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	b.wait()
+	return b.backend.GetZone(zone, records)
+}
+
+// GetRecord implements the Backend interface.
+func (b *RateLimitedBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	b.wait()
+	return b.backend.GetRecord(zone, domain, recordType)
+}
+
+// GetQPS implements the Backend interface.
+func (b *RateLimitedBackend) GetQPS() (float32, error) {
+	b.wait()
+	return b.backend.GetQPS()
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *RateLimitedBackend) GetZoneQPS(zone string) (float32, error) {
+	b.wait()
+	return b.backend.GetZoneQPS(zone)
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *RateLimitedBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	b.wait()
+	return b.backend.GetRecordQPS(zone, domain, recordType)
+}
+
+// ListActivity implements the Backend interface.
+func (b *RateLimitedBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	b.wait()
+	return b.backend.ListActivity(params...)
+}
+
+// Call describes a single call made through a RecordingBackend.
+type Call struct {
+	Method   string
+	Args     []string
+	Response any
+	Err      error
+	Duration time.Duration
+}
+
+// Recorder receives the calls made through a RecordingBackend.
+type Recorder interface {
+	Record(call Call)
+}
+
+// CallLog is a Recorder that keeps every recorded call in memory.
+type CallLog struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Record implements the Recorder interface.
+func (l *CallLog) Record(call Call) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, call)
+}
+
+// Calls returns a copy of all calls recorded so far.
+func (l *CallLog) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	calls := make([]Call, len(l.calls))
+	copy(calls, l.calls)
+
+	return calls
+}
+
+// RecordingBackend is a Backend that passes every call made to the wrapped
+// Backend, along with its response, to a Recorder.
+type RecordingBackend struct {
+	backend  Backend
+	recorder Recorder
+}
+
+// NewRecordingBackend wraps a Backend so that all calls are recorded.
+func NewRecordingBackend(backend Backend, recorder Recorder) *RecordingBackend {
+	return &RecordingBackend{
+		backend:  backend,
+		recorder: recorder,
+	}
+}
+
+func record[T any](b *RecordingBackend, method string, args []string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	value, err := fn()
+	b.recorder.Record(Call{
+		Method:   method,
+		Args:     args,
+		Response: value,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+
+	return value, err
+}
+
+// ListZones implements the Backend interface.
+func (b *RecordingBackend) ListZones() ([]*dns.Zone, error) {
+	return record(b, MethodListZones, nil, b.backend.ListZones)
+}
+
+// ListViews implements the Backend interface.
+func (b *RecordingBackend) ListViews() ([]*dns.View, error) {
+	return record(b, MethodListViews, nil, b.backend.ListViews)
+}
+
+// GetZone implements the Backend interface.
+func (b *RecordingBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	return record(b, MethodGetZone, []string{zone, strconv.FormatBool(records)}, func() (*dns.Zone, error) {
+		return b.backend.GetZone(zone, records)
+	})
+}
+
+// GetRecord implements the Backend interface.
+func (b *RecordingBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return record(b, MethodGetRecord, []string{zone, domain, recordType}, func() (*dns.Record, error) {
+		return b.backend.GetRecord(zone, domain, recordType)
+	})
+}
+
+// GetQPS implements the Backend interface.
+func (b *RecordingBackend) GetQPS() (float32, error) {
+	return record(b, MethodGetQPS, nil, b.backend.GetQPS)
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *RecordingBackend) GetZoneQPS(zone string) (float32, error) {
+	return record(b, MethodGetZoneQPS, []string{zone}, func() (float32, error) {
+		return b.backend.GetZoneQPS(zone)
+	})
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *RecordingBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	return record(b, MethodGetRecordQPS, []string{zone, domain, recordType}, func() (float32, error) {
+		return b.backend.GetRecordQPS(zone, domain, recordType)
+	})
+}
+
+// ListActivity implements the Backend interface.
+func (b *RecordingBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	args := make([]string, len(params))
+	for i, p := range params {
+		args[i] = p.Key + "=" + p.Value
+	}
+
+	return record(b, MethodListActivity, args, func() ([]*account.Activity, error) {
+		return b.backend.ListActivity(params...)
+	})
+}
+
+// callKey builds a unique key for a call to a Backend method with the given
+// arguments.
+func callKey(method string, args ...string) string {
+	return method + "/" + strings.Join(args, "/")
+}
@@ -0,0 +1,246 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+var (
+	errMockBackend   = errors.New("mock backend error")
+	errMockTransient = &api.Error{Resp: &http.Response{StatusCode: http.StatusServiceUnavailable}, Message: "mock transient error"}
+)
+
+// mockBackend is a minimal in-memory Backend that counts calls per method.
+// If transientFailures is set, that many calls fail with a transient error
+// before calls succeed again.
+type mockBackend struct {
+	mu                sync.Mutex
+	calls             map[string]int
+	fail              bool
+	transientFailures int
+}
+
+func newMockBackend() *mockBackend {
+	return &mockBackend{calls: make(map[string]int)}
+}
+
+func (b *mockBackend) count(method string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.calls[method]++
+	if b.fail {
+		return errMockBackend
+	}
+	if b.transientFailures > 0 {
+		b.transientFailures--
+		return errMockTransient
+	}
+
+	return nil
+}
+
+func (b *mockBackend) ListZones() ([]*dns.Zone, error) {
+	return []*dns.Zone{{Zone: "foo.bar"}}, b.count(MethodListZones)
+}
+
+func (b *mockBackend) ListViews() ([]*dns.View, error) {
+	return []*dns.View{{Name: "internal", Zones: []string{"foo.bar"}}}, b.count(MethodListViews)
+}
+
+func (b *mockBackend) GetZone(zone string, _ bool) (*dns.Zone, error) {
+	return &dns.Zone{Zone: zone}, b.count(MethodGetZone)
+}
+
+func (b *mockBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return &dns.Record{Zone: zone, Domain: domain, Type: recordType}, b.count(MethodGetRecord)
+}
+
+func (b *mockBackend) GetQPS() (float32, error) {
+	return 100, b.count(MethodGetQPS)
+}
+
+func (b *mockBackend) GetZoneQPS(_ string) (float32, error) {
+	return 10, b.count(MethodGetZoneQPS)
+}
+
+func (b *mockBackend) GetRecordQPS(_, _, _ string) (float32, error) {
+	return 1, b.count(MethodGetRecordQPS)
+}
+
+func (b *mockBackend) ListActivity(_ ...api.Param) ([]*account.Activity, error) {
+	return nil, b.count(MethodListActivity)
+}
+
+func TestCachingBackend(t *testing.T) {
+	tests := map[string]struct {
+		ttl       time.Duration
+		fail      bool
+		wantCalls map[string]int
+	}{
+		"cached": {ttl: time.Hour, wantCalls: map[string]int{
+			MethodListZones: 1, MethodGetZone: 2, MethodGetRecord: 1, MethodGetQPS: 2,
+		}},
+		"expired": {ttl: time.Nanosecond, wantCalls: map[string]int{
+			MethodListZones: 2, MethodGetZone: 3, MethodGetRecord: 2, MethodGetQPS: 2,
+		}},
+		"errors_not_cached": {ttl: time.Hour, fail: true, wantCalls: map[string]int{
+			MethodListZones: 2, MethodGetZone: 3, MethodGetRecord: 2, MethodGetQPS: 2,
+		}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock := newMockBackend()
+			mock.fail = tc.fail
+			backend := NewCachingBackend(mock, tc.ttl)
+
+			for range 2 {
+				time.Sleep(time.Millisecond)
+				backend.ListZones()
+				backend.GetZone("foo.bar", true)
+				backend.GetRecord("foo.bar", "test.foo.bar", "A")
+				backend.GetQPS()
+			}
+			// different args must not share a cache entry
+			backend.GetZone("foo.bar", false)
+
+			require.Equal(t, tc.wantCalls, mock.calls)
+		})
+	}
+}
+
+// activityBackend is a Backend that lists the provided account activity, and
+// gets records identified by their domain.
+type activityBackend struct {
+	*mockBackend
+	activity []*account.Activity
+}
+
+func (b *activityBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return &dns.Record{ID: domain, Zone: zone, Domain: domain, Type: recordType}, b.count(MethodGetRecord)
+}
+
+func (b *activityBackend) ListActivity(_ ...api.Param) ([]*account.Activity, error) {
+	return b.activity, b.count(MethodListActivity)
+}
+
+func TestCachingBackendActivity(t *testing.T) {
+	tests := map[string]struct {
+		activity  []*account.Activity
+		wantCalls map[string]int
+	}{
+		"no_activity": {wantCalls: map[string]int{
+			MethodListZones: 1, MethodGetZone: 1, MethodGetRecord: 2,
+		}},
+		"other_activity": {
+			activity: []*account.Activity{{ResourceType: "user", ResourceID: "test.foo.bar"}},
+			wantCalls: map[string]int{
+				MethodListZones: 1, MethodGetZone: 1, MethodGetRecord: 2,
+			},
+		},
+		"record_activity": {
+			activity: []*account.Activity{{ResourceType: "record", ResourceID: "test.foo.bar"}},
+			wantCalls: map[string]int{
+				MethodListZones: 1, MethodGetZone: 2, MethodGetRecord: 3,
+			},
+		},
+		"zone_activity": {
+			activity: []*account.Activity{{ResourceType: "datafeed", ResourceID: "feed"}},
+			wantCalls: map[string]int{
+				MethodListZones: 2, MethodGetZone: 2, MethodGetRecord: 4,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock := &activityBackend{mockBackend: newMockBackend(), activity: tc.activity}
+			backend := NewCachingBackend(mock, time.Hour)
+
+			lookup := func() {
+				backend.ListZones()
+				backend.GetZone("foo.bar", true)
+				backend.GetRecord("foo.bar", "test.foo.bar", "A")
+				backend.GetRecord("foo.bar", "keep.foo.bar", "A")
+			}
+
+			lookup()
+			_, err := backend.ListActivity()
+			require.NoError(t, err)
+			lookup()
+
+			delete(mock.calls, MethodListActivity)
+			require.Equal(t, tc.wantCalls, mock.calls)
+		})
+	}
+}
+
+func TestRateLimitedBackend(t *testing.T) {
+	mock := newMockBackend()
+	backend := NewRateLimitedBackend(mock, 20)
+
+	start := time.Now()
+	for range 30 {
+		_, err := backend.GetQPS()
+		require.NoError(t, err)
+	}
+
+	// a burst of 20 is allowed immediately, the remaining 10 calls take
+	// ~500ms at 20 requests per second
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	require.Equal(t, 30, mock.calls[MethodGetQPS])
+}
+
+func TestRecordingBackend(t *testing.T) {
+	mock := newMockBackend()
+	log := &CallLog{}
+	backend := NewRecordingBackend(mock, log)
+
+	backend.GetZone("foo.bar", true)
+	backend.GetRecordQPS("foo.bar", "test.foo.bar", "A")
+	backend.ListActivity(api.Param{Key: "limit", Value: "1000"})
+
+	mock.fail = true
+	backend.GetQPS()
+
+	calls := log.Calls()
+	require.Len(t, calls, 4)
+
+	require.Equal(t, MethodGetZone, calls[0].Method)
+	require.Equal(t, []string{"foo.bar", "true"}, calls[0].Args)
+	require.Equal(t, &dns.Zone{Zone: "foo.bar"}, calls[0].Response)
+	require.NoError(t, calls[0].Err)
+
+	require.Equal(t, MethodGetRecordQPS, calls[1].Method)
+	require.Equal(t, []string{"foo.bar", "test.foo.bar", "A"}, calls[1].Args)
+	require.InDelta(t, float32(1), calls[1].Response, 0)
+
+	require.Equal(t, MethodListActivity, calls[2].Method)
+	require.Equal(t, []string{"limit=1000"}, calls[2].Args)
+
+	require.Equal(t, MethodGetQPS, calls[3].Method)
+	require.ErrorIs(t, calls[3].Err, errMockBackend)
+}
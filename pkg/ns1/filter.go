@@ -0,0 +1,125 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+)
+
+// RecordFilter selects records by domain, type, tags and meta. Unset fields
+// match every record. All regular expressions are unanchored, the same as the
+// zone blacklist/whitelist.
+type RecordFilter struct {
+	DomainBlacklist *regexp.Regexp
+	DomainWhitelist *regexp.Regexp
+	TypeWhitelist   *regexp.Regexp
+	// Tags maps tag keys to a regular expression that the record's value
+	// for the tag must match. Records without the tag don't match.
+	Tags map[string]*regexp.Regexp
+	// Meta maps meta keys to a regular expression that the record's value
+	// for the meta key must match. Records without the meta key don't
+	// match. Meta is not included in zone data, so filtering by meta
+	// requires looking up each record that otherwise matches.
+	Meta map[string]*regexp.Regexp
+}
+
+func isSet(re *regexp.Regexp) bool {
+	return re != nil && re.String() != ""
+}
+
+// NeedsMeta returns whether the filter matches on record meta, in which case
+// the full record must be looked up to call MatchMeta.
+func (f *RecordFilter) NeedsMeta() bool {
+	return f != nil && len(f.Meta) > 0
+}
+
+// Match returns whether a record matches the filter's domain, type and tag
+// conditions.
+func (f *RecordFilter) Match(r *ZoneRecord) bool {
+	if f == nil {
+		return true
+	}
+
+	if isSet(f.DomainBlacklist) && f.DomainBlacklist.MatchString(r.Domain) {
+		return false
+	}
+
+	if isSet(f.DomainWhitelist) && !f.DomainWhitelist.MatchString(r.Domain) {
+		return false
+	}
+
+	if isSet(f.TypeWhitelist) && !f.TypeWhitelist.MatchString(r.Type) {
+		return false
+	}
+
+	for key, re := range f.Tags {
+		value, ok := r.Tags[key]
+		if !ok || !re.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchMeta returns whether a record's meta matches the filter's meta
+// conditions.
+func (f *RecordFilter) MatchMeta(meta *data.Meta) bool {
+	if !f.NeedsMeta() {
+		return true
+	}
+
+	if meta == nil {
+		return false
+	}
+
+	metaMap := meta.StringMap()
+	for key, re := range f.Meta {
+		value, ok := metaMap[key]
+		if !ok || !re.MatchString(fmt.Sprintf("%v", value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseMatchers parses a list of `key=regex` strings, as used for tag and meta
+// filters, into a map of keys to compiled regular expressions.
+func ParseMatchers(matchers []string) (map[string]*regexp.Regexp, error) {
+	if len(matchers) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]*regexp.Regexp, len(matchers))
+	for _, m := range matchers {
+		key, expr, ok := strings.Cut(m, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid matcher %q, expected key=regex", m)
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression in matcher %q: %w", m, err)
+		}
+		parsed[key] = re
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+)
+
+func TestRecordFilterMatch(t *testing.T) {
+	record := &ZoneRecord{Domain: "www.foo.bar", Type: "A", Tags: map[string]string{"team": "dns"}}
+
+	tests := map[string]struct {
+		filter *RecordFilter
+		want   bool
+	}{
+		"nil_filter":         {filter: nil, want: true},
+		"empty_filter":       {filter: &RecordFilter{DomainBlacklist: regexp.MustCompile("")}, want: true},
+		"domain_blacklisted": {filter: &RecordFilter{DomainBlacklist: regexp.MustCompile("^www")}, want: false},
+		"domain_whitelisted": {filter: &RecordFilter{DomainWhitelist: regexp.MustCompile("^www")}, want: true},
+		"domain_not_listed":  {filter: &RecordFilter{DomainWhitelist: regexp.MustCompile("^api")}, want: false},
+		"type_match":         {filter: &RecordFilter{TypeWhitelist: regexp.MustCompile("^(A|AAAA)$")}, want: true},
+		"type_mismatch":      {filter: &RecordFilter{TypeWhitelist: regexp.MustCompile("^TXT$")}, want: false},
+		"tag_match":          {filter: &RecordFilter{Tags: map[string]*regexp.Regexp{"team": regexp.MustCompile("^dns$")}}, want: true},
+		"tag_mismatch":       {filter: &RecordFilter{Tags: map[string]*regexp.Regexp{"team": regexp.MustCompile("^web$")}}, want: false},
+		"tag_missing":        {filter: &RecordFilter{Tags: map[string]*regexp.Regexp{"env": regexp.MustCompile(".*")}}, want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.filter.Match(record))
+		})
+	}
+}
+
+func TestRecordFilterMatchMeta(t *testing.T) {
+	filter := &RecordFilter{Meta: map[string]*regexp.Regexp{"up": regexp.MustCompile("^1$")}}
+	require.True(t, filter.NeedsMeta())
+
+	require.True(t, filter.MatchMeta(&data.Meta{Up: true}))
+	require.False(t, filter.MatchMeta(&data.Meta{Up: false}))
+	require.False(t, filter.MatchMeta(&data.Meta{Priority: 1}))
+	require.False(t, filter.MatchMeta(nil))
+
+	var noMeta *RecordFilter
+	require.False(t, noMeta.NeedsMeta())
+	require.True(t, noMeta.MatchMeta(nil))
+}
+
+func TestParseMatchers(t *testing.T) {
+	tests := map[string]struct {
+		matchers []string
+		wantKeys []string
+		wantErr  bool
+	}{
+		"empty":         {matchers: nil},
+		"valid":         {matchers: []string{"team=dns|web", "env=prod"}, wantKeys: []string{"env", "team"}},
+		"missing_equal": {matchers: []string{"team"}, wantErr: true},
+		"missing_key":   {matchers: []string{"=dns"}, wantErr: true},
+		"invalid_regex": {matchers: []string{"team=("}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMatchers(tc.matchers)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var keys []string
+			for k := range got {
+				keys = append(keys, k)
+			}
+			require.ElementsMatch(t, tc.wantKeys, keys)
+		})
+	}
+}
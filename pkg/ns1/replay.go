@@ -0,0 +1,261 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	"github.com/tjhop/ns1_exporter/pkg/storage"
+)
+
+const redacted = "REDACTED"
+
+// ErrFixtureNotFound is returned by a ReplayBackend when no recorded fixture
+// exists for a call.
+var ErrFixtureNotFound = errors.New("no recorded fixture for call")
+
+// fixture is the on-disk format of a single recorded call.
+type fixture struct {
+	Method   string          `json:"method"`
+	Args     []string        `json:"args,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// fixtureFileName returns the name of the fixture file for a call. Account
+// activity is always requested with a varying start time, so its arguments
+// are not part of the name and only the most recent activity call is kept.
+func fixtureFileName(method string, args []string) string {
+	parts := []string{method}
+	if method != MethodListActivity {
+		for _, arg := range args {
+			parts = append(parts, url.QueryEscape(arg))
+		}
+	}
+
+	return strings.Join(parts, ",") + ".json"
+}
+
+// sanitizeResponse returns a copy of a Backend response with fields that
+// contain credentials or personal information removed, so that recorded
+// fixtures can be shared safely.
+func sanitizeResponse(response any) any {
+	switch v := response.(type) {
+	case *dns.Zone:
+		return sanitizeZone(v)
+	case []*dns.Zone:
+		zones := make([]*dns.Zone, len(v))
+		for i, z := range v {
+			zones[i] = sanitizeZone(z)
+		}
+		return zones
+	case []*account.Activity:
+		activity := make([]*account.Activity, len(v))
+		for i, a := range v {
+			if a == nil {
+				continue
+			}
+			sanitized := *a
+			sanitized.UserID = ""
+			sanitized.UserName = ""
+			sanitized.UserType = ""
+			activity[i] = &sanitized
+		}
+		return activity
+	default:
+		return response
+	}
+}
+
+// sanitizeZone returns a copy of a zone with its TSIG key redacted. Nil zones
+// are returned as they are.
+func sanitizeZone(z *dns.Zone) *dns.Zone {
+	if z == nil {
+		return nil
+	}
+
+	sanitized := *z
+	if z.Secondary != nil && z.Secondary.TSIG != nil {
+		secondary := *z.Secondary
+		tsig := *z.Secondary.TSIG
+		if tsig.Key != "" {
+			tsig.Key = redacted
+		}
+		secondary.TSIG = &tsig
+		sanitized.Secondary = &secondary
+	}
+
+	return &sanitized
+}
+
+// FileRecorder is a Recorder that writes each recorded call to a fixture file
+// in a directory, for later use by a ReplayBackend. Responses are sanitized
+// before being written. Repeated calls overwrite the previous fixture.
+type FileRecorder struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// NewFileRecorder creates a new FileRecorder that writes fixtures to the
+// provided directory, creating it if needed.
+func NewFileRecorder(logger *slog.Logger, dir string) (*FileRecorder, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create record directory: %w", err)
+	}
+
+	return &FileRecorder{
+		dir:    dir,
+		logger: logger.With("component", "recorder"),
+	}, nil
+}
+
+// Record implements the Recorder interface.
+func (r *FileRecorder) Record(call Call) {
+	f := fixture{
+		Method: call.Method,
+		Args:   call.Args,
+	}
+
+	if call.Err != nil {
+		f.Error = call.Err.Error()
+	} else {
+		raw, err := json.Marshal(sanitizeResponse(call.Response))
+		if err != nil {
+			r.logger.Error("Failed to encode NS1 API response for recording", "err", err, "method", call.Method)
+			return
+		}
+		f.Response = raw
+	}
+
+	buf, err := json.MarshalIndent(f, "", "    ")
+	if err != nil {
+		r.logger.Error("Failed to encode fixture for recording", "err", err, "method", call.Method)
+		return
+	}
+
+	path := filepath.Join(r.dir, fixtureFileName(call.Method, call.Args))
+	if err := storage.WriteFileAtomic(path, buf, 0o640); err != nil {
+		r.logger.Error("Failed to write fixture", "err", err, "path", path)
+		return
+	}
+	r.logger.Debug("Recorded NS1 API call", "method", call.Method, "args", call.Args, "path", path)
+}
+
+// ReplayBackend is a Backend that serves responses from fixtures written by a
+// FileRecorder, without making any requests to the NS1 API.
+type ReplayBackend struct {
+	dir string
+}
+
+// NewReplayBackend creates a new ReplayBackend that reads fixtures from the
+// provided directory.
+func NewReplayBackend(dir string) (*ReplayBackend, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replay path is not a directory: %s", dir)
+	}
+
+	return &ReplayBackend{dir: dir}, nil
+}
+
+func replay[T any](b *ReplayBackend, method string, args ...string) (T, error) {
+	var value T
+
+	name := fixtureFileName(method, args)
+	buf, err := os.ReadFile(filepath.Join(b.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return value, fmt.Errorf("%w: %s", ErrFixtureNotFound, name)
+		}
+		return value, err
+	}
+
+	var f fixture
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return value, fmt.Errorf("failed to decode fixture %s: %w", name, err)
+	}
+
+	if f.Error != "" {
+		// preserve well known NS1 SDK errors so that callers checking
+		// for them behave the same as they would against the API
+		for _, sentinel := range []error{api.ErrZoneMissing, api.ErrRecordMissing} {
+			if f.Error == sentinel.Error() {
+				return value, sentinel
+			}
+		}
+		return value, errors.New(f.Error)
+	}
+
+	if err := json.Unmarshal(f.Response, &value); err != nil {
+		return value, fmt.Errorf("failed to decode fixture response %s: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// ListZones implements the Backend interface.
+func (b *ReplayBackend) ListZones() ([]*dns.Zone, error) {
+	return replay[[]*dns.Zone](b, MethodListZones)
+}
+
+// ListViews implements the Backend interface.
+func (b *ReplayBackend) ListViews() ([]*dns.View, error) {
+	return replay[[]*dns.View](b, MethodListViews)
+}
+
+// GetZone implements the Backend interface.
+func (b *ReplayBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	return replay[*dns.Zone](b, MethodGetZone, zone, strconv.FormatBool(records))
+}
+
+// GetRecord implements the Backend interface.
+func (b *ReplayBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return replay[*dns.Record](b, MethodGetRecord, zone, domain, recordType)
+}
+
+// GetQPS implements the Backend interface.
+func (b *ReplayBackend) GetQPS() (float32, error) {
+	return replay[float32](b, MethodGetQPS)
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *ReplayBackend) GetZoneQPS(zone string) (float32, error) {
+	return replay[float32](b, MethodGetZoneQPS, zone)
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *ReplayBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	return replay[float32](b, MethodGetRecordQPS, zone, domain, recordType)
+}
+
+// ListActivity implements the Backend interface.
+func (b *ReplayBackend) ListActivity(_ ...api.Param) ([]*account.Activity, error) {
+	return replay[[]*account.Activity](b, MethodListActivity)
+}
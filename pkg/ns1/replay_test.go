@@ -0,0 +1,116 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder, err := NewFileRecorder(mockLogger, dir)
+	require.NoError(t, err)
+	mock := newMockBackend()
+	recording := NewRecordingBackend(mock, recorder)
+
+	zones, err := recording.ListZones()
+	require.NoError(t, err)
+	zone, err := recording.GetZone("foo.bar", true)
+	require.NoError(t, err)
+	record, err := recording.GetRecord("foo.bar", "_dmarc.foo.bar", "TXT")
+	require.NoError(t, err)
+	qps, err := recording.GetRecordQPS("foo.bar", "*.foo.bar", "A")
+	require.NoError(t, err)
+	mock.fail = true
+	_, err = recording.GetQPS()
+	require.Error(t, err)
+
+	replay, err := NewReplayBackend(dir)
+	require.NoError(t, err)
+
+	gotZones, err := replay.ListZones()
+	require.NoError(t, err)
+	require.Equal(t, zones, gotZones)
+
+	gotZone, err := replay.GetZone("foo.bar", true)
+	require.NoError(t, err)
+	require.Equal(t, zone, gotZone)
+
+	gotRecord, err := replay.GetRecord("foo.bar", "_dmarc.foo.bar", "TXT")
+	require.NoError(t, err)
+	require.Equal(t, record, gotRecord)
+
+	gotQPS, err := replay.GetRecordQPS("foo.bar", "*.foo.bar", "A")
+	require.NoError(t, err)
+	require.InDelta(t, qps, gotQPS, 0)
+
+	_, err = replay.GetQPS()
+	require.EqualError(t, err, errMockBackend.Error())
+
+	_, err = replay.GetZone("foo.bar", false)
+	require.ErrorIs(t, err, ErrFixtureNotFound)
+}
+
+func TestReplaySentinelErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder, err := NewFileRecorder(mockLogger, dir)
+	require.NoError(t, err)
+	recorder.Record(Call{Method: MethodGetZoneQPS, Args: []string{"missing.zone"}, Err: api.ErrZoneMissing})
+
+	replay, err := NewReplayBackend(dir)
+	require.NoError(t, err)
+
+	_, err = replay.GetZoneQPS("missing.zone")
+	require.ErrorIs(t, err, api.ErrZoneMissing)
+}
+
+func TestSanitizeResponse(t *testing.T) {
+	tests := map[string]struct {
+		response any
+		want     any
+	}{
+		"activity": {
+			response: []*account.Activity{{UserID: "1234", UserName: "someone", UserType: "user", ResourceType: "record", Timestamp: 1700000000}},
+			want:     []*account.Activity{{ResourceType: "record", Timestamp: 1700000000}},
+		},
+		"zone_tsig": {
+			response: &dns.Zone{Zone: "foo.bar", Secondary: &dns.ZoneSecondary{Enabled: true, TSIG: &dns.TSIG{Enabled: true, Key: "c2VjcmV0"}}},
+			want:     &dns.Zone{Zone: "foo.bar", Secondary: &dns.ZoneSecondary{Enabled: true, TSIG: &dns.TSIG{Enabled: true, Key: redacted}}},
+		},
+		"zones_nil": {
+			response: []*dns.Zone{nil, {Zone: "foo.bar"}},
+			want:     []*dns.Zone{nil, {Zone: "foo.bar"}},
+		},
+		"activity_nil": {
+			response: []*account.Activity{nil, {UserID: "1234", ResourceType: "record"}},
+			want:     []*account.Activity{nil, {ResourceType: "record"}},
+		},
+		"zone_nil": {response: (*dns.Zone)(nil), want: (*dns.Zone)(nil)},
+		"qps":      {response: float32(10), want: float32(10)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, sanitizeResponse(tc.response))
+		})
+	}
+}
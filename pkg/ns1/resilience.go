@@ -0,0 +1,372 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+// ErrCircuitOpen is returned by a CircuitBreakerBackend when calls to the NS1
+// API are being skipped because of repeated failures.
+var ErrCircuitOpen = errors.New("circuit breaker open, skipping NS1 API call")
+
+// IsTransient returns whether an error returned by a Backend is likely to be
+// temporary, such as a timeout, a reset connection, a rate limited request or a
+// server error, in which case the call may succeed if it is retried later.
+// Other network errors, such as TLS or proxy misconfigurations, fail the same
+// way on every attempt and aren't transient.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) && apiErr.Resp != nil {
+		return apiErr.Resp.StatusCode == http.StatusTooManyRequests || apiErr.Resp.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var tempErr interface{ Temporary() bool }
+	if errors.As(err, &tempErr) && tempErr.Temporary() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
+// RetryConfig configures the retries of a RetryingBackend.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of times a failed call is retried.
+	MaxRetries int
+	// InitialBackoff is the upper bound of the delay before the first
+	// retry. The bound doubles with each retry, up to MaxBackoff, and the
+	// actual delay is chosen at random below it.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryingBackend is a Backend that retries calls to the wrapped Backend that
+// fail with a transient error, using exponential backoff with full jitter.
+// All Backend methods are read-only, so every call is safe to retry.
+type RetryingBackend struct {
+	backend Backend
+	config  RetryConfig
+	sleep   func(time.Duration)
+}
+
+// NewRetryingBackend wraps a Backend so that calls failing with a transient
+// error are retried.
+func NewRetryingBackend(backend Backend, config RetryConfig) *RetryingBackend {
+	return &RetryingBackend{
+		backend: backend,
+		config:  config,
+		sleep:   time.Sleep,
+	}
+}
+
+// backoff returns the delay before the provided retry attempt, starting at 0.
+func (b *RetryingBackend) backoff(attempt int) time.Duration {
+	bound := b.config.InitialBackoff << attempt
+	if bound <= 0 || (b.config.MaxBackoff > 0 && bound > b.config.MaxBackoff) {
+		// the shift overflowed or exceeded the configured max
+		bound = b.config.MaxBackoff
+	}
+	if bound <= 0 {
+		return 0
+	}
+
+	return rand.N(bound) //nolint:gosec // jitter does not need a secure random source
+}
+
+func retry[T any](b *RetryingBackend, method string, fn func() (T, error)) (T, error) {
+	value, err := fn()
+	for attempt := 0; attempt < b.config.MaxRetries && IsTransient(err); attempt++ {
+		b.sleep(b.backoff(attempt))
+		metrics.MetricNS1APIRetries.WithLabelValues(method).Inc()
+		value, err = fn()
+	}
+
+	return value, err
+}
+
+// ListZones implements the Backend interface.
+func (b *RetryingBackend) ListZones() ([]*dns.Zone, error) {
+	return retry(b, MethodListZones, b.backend.ListZones)
+}
+
+// ListViews implements the Backend interface.
+func (b *RetryingBackend) ListViews() ([]*dns.View, error) {
+	return retry(b, MethodListViews, b.backend.ListViews)
+}
+
+// GetZone implements the Backend interface.
+func (b *RetryingBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	return retry(b, MethodGetZone, func() (*dns.Zone, error) {
+		return b.backend.GetZone(zone, records)
+	})
+}
+
+// GetRecord implements the Backend interface.
+func (b *RetryingBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return retry(b, MethodGetRecord, func() (*dns.Record, error) {
+		return b.backend.GetRecord(zone, domain, recordType)
+	})
+}
+
+// GetQPS implements the Backend interface.
+func (b *RetryingBackend) GetQPS() (float32, error) {
+	return retry(b, MethodGetQPS, b.backend.GetQPS)
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *RetryingBackend) GetZoneQPS(zone string) (float32, error) {
+	return retry(b, MethodGetZoneQPS, func() (float32, error) {
+		return b.backend.GetZoneQPS(zone)
+	})
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *RetryingBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	return retry(b, MethodGetRecordQPS, func() (float32, error) {
+		return b.backend.GetRecordQPS(zone, domain, recordType)
+	})
+}
+
+// ListActivity implements the Backend interface.
+func (b *RetryingBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	return retry(b, MethodListActivity, func() ([]*account.Activity, error) {
+		return b.backend.ListActivity(params...)
+	})
+}
+
+// CircuitState is the state of a CircuitBreakerBackend.
+type CircuitState int
+
+const (
+	// CircuitClosed passes all calls through to the wrapped Backend.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all calls with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen passes a single trial call through to the wrapped
+	// Backend to check whether the NS1 API has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
+
+// CircuitBreakerBackend is a Backend that stops calling the wrapped Backend
+// after a number of consecutive calls fail with a transient error, so that an
+// NS1 API outage isn't made worse by continued requests. After the open
+// timeout, a single trial call is let through, which closes the circuit again
+// if it succeeds.
+type CircuitBreakerBackend struct {
+	backend   Backend
+	threshold int
+	timeout   time.Duration
+	logger    *slog.Logger
+
+	mu              sync.Mutex
+	state           CircuitState
+	failures        int
+	openedAt        time.Time
+	trialInProgress bool
+}
+
+// NewCircuitBreakerBackend wraps a Backend with a circuit breaker that opens
+// after threshold consecutive transient failures, and stays open for timeout
+// before letting a trial call through.
+func NewCircuitBreakerBackend(logger *slog.Logger, backend Backend, threshold int, timeout time.Duration) *CircuitBreakerBackend {
+	metrics.MetricNS1APICircuitBreakerState.Set(float64(CircuitClosed))
+
+	return &CircuitBreakerBackend{
+		backend:   backend,
+		threshold: threshold,
+		timeout:   timeout,
+		logger:    logger.With("component", "circuit_breaker"),
+	}
+}
+
+// State returns the current state of the circuit breaker.
+func (b *CircuitBreakerBackend) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// setState must be called with the lock held.
+func (b *CircuitBreakerBackend) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	b.logger.Warn("NS1 API circuit breaker state changed", "from", b.state.String(), "to", state.String(), "consecutive_failures", b.failures)
+	b.state = state
+	metrics.MetricNS1APICircuitBreakerState.Set(float64(state))
+}
+
+// allow returns whether a call may be made to the wrapped Backend, and whether
+// the call is the trial call of a half-open circuit.
+func (b *CircuitBreakerBackend) allow() (ok, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false, false
+		}
+		b.setState(CircuitHalfOpen)
+		b.trialInProgress = true
+		return true, true
+	case CircuitHalfOpen:
+		if b.trialInProgress {
+			return false, false
+		}
+		b.trialInProgress = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// done updates the state of the circuit breaker with the result of a call.
+// Only the result of the trial call decides whether a half-open circuit is
+// closed or reopened, calls that were started before the circuit opened only
+// count towards opening a closed circuit.
+func (b *CircuitBreakerBackend) done(trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trialInProgress = false
+		if IsTransient(err) {
+			b.openedAt = time.Now()
+			b.setState(CircuitOpen)
+			return
+		}
+
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return
+	}
+
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if IsTransient(err) {
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+			b.setState(CircuitOpen)
+		}
+		return
+	}
+
+	b.failures = 0
+}
+
+func guard[T any](b *CircuitBreakerBackend, fn func() (T, error)) (T, error) {
+	ok, trial := b.allow()
+	if !ok {
+		var value T
+		return value, ErrCircuitOpen
+	}
+
+	value, err := fn()
+	b.done(trial, err)
+
+	return value, err
+}
+
+// ListZones implements the Backend interface.
+func (b *CircuitBreakerBackend) ListZones() ([]*dns.Zone, error) {
+	return guard(b, b.backend.ListZones)
+}
+
+// ListViews implements the Backend interface.
+func (b *CircuitBreakerBackend) ListViews() ([]*dns.View, error) {
+	return guard(b, b.backend.ListViews)
+}
+
+// GetZone implements the Backend interface.
+func (b *CircuitBreakerBackend) GetZone(zone string, records bool) (*dns.Zone, error) {
+	return guard(b, func() (*dns.Zone, error) {
+		return b.backend.GetZone(zone, records)
+	})
+}
+
+// GetRecord implements the Backend interface.
+func (b *CircuitBreakerBackend) GetRecord(zone, domain, recordType string) (*dns.Record, error) {
+	return guard(b, func() (*dns.Record, error) {
+		return b.backend.GetRecord(zone, domain, recordType)
+	})
+}
+
+// GetQPS implements the Backend interface.
+func (b *CircuitBreakerBackend) GetQPS() (float32, error) {
+	return guard(b, b.backend.GetQPS)
+}
+
+// GetZoneQPS implements the Backend interface.
+func (b *CircuitBreakerBackend) GetZoneQPS(zone string) (float32, error) {
+	return guard(b, func() (float32, error) {
+		return b.backend.GetZoneQPS(zone)
+	})
+}
+
+// GetRecordQPS implements the Backend interface.
+func (b *CircuitBreakerBackend) GetRecordQPS(zone, domain, recordType string) (float32, error) {
+	return guard(b, func() (float32, error) {
+		return b.backend.GetRecordQPS(zone, domain, recordType)
+	})
+}
+
+// ListActivity implements the Backend interface.
+func (b *CircuitBreakerBackend) ListActivity(params ...api.Param) ([]*account.Activity, error) {
+	return guard(b, func() ([]*account.Activity, error) {
+		return b.backend.ListActivity(params...)
+	})
+}
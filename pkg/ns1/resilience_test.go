@@ -0,0 +1,166 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":            {err: nil, want: false},
+		"server_error":   {err: errMockTransient, want: true},
+		"rate_limited":   {err: &api.Error{Resp: &http.Response{StatusCode: http.StatusTooManyRequests}}, want: true},
+		"not_found":      {err: &api.Error{Resp: &http.Response{StatusCode: http.StatusNotFound}}, want: false},
+		"zone_missing":   {err: api.ErrZoneMissing, want: false},
+		"timeout":        {err: fmt.Errorf("wrapped: %w", &timeoutError{}), want: true},
+		"url_timeout":    {err: &url.Error{Op: "Get", URL: "https://api.nsone.net", Err: &timeoutError{}}, want: true},
+		"conn_reset":     {err: &url.Error{Op: "Get", URL: "https://api.nsone.net", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, want: true},
+		"tls_error":      {err: &url.Error{Op: "Get", URL: "https://api.nsone.net", Err: x509.UnknownAuthorityError{}}, want: false},
+		"dns_not_found":  {err: &url.Error{Op: "Get", URL: "https://api.nsone.net", Err: &net.DNSError{IsNotFound: true}}, want: false},
+		"circuit_open":   {err: ErrCircuitOpen, want: false},
+		"other_error":    {err: errMockBackend, want: false},
+		"context_cancel": {err: context.Canceled, want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, IsTransient(tc.err))
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+func TestRetryingBackend(t *testing.T) {
+	tests := map[string]struct {
+		transientFailures int
+		fail              bool
+		wantErr           error
+		wantCalls         int
+	}{
+		"success":            {wantCalls: 1},
+		"recovers":           {transientFailures: 2, wantCalls: 3},
+		"exhausted":          {transientFailures: 5, wantErr: errMockTransient, wantCalls: 4},
+		"permanent_no_retry": {fail: true, wantErr: errMockBackend, wantCalls: 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock := newMockBackend()
+			mock.fail = tc.fail
+			mock.transientFailures = tc.transientFailures
+
+			backend := NewRetryingBackend(mock, RetryConfig{MaxRetries: 3, InitialBackoff: time.Second, MaxBackoff: 4 * time.Second})
+			var delays []time.Duration
+			backend.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+			_, err := backend.ListZones()
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.wantCalls, mock.calls[MethodListZones])
+
+			require.Len(t, delays, tc.wantCalls-1)
+			for i, d := range delays {
+				require.Less(t, d, min(time.Second<<i, 4*time.Second))
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerBackend(t *testing.T) {
+	mock := newMockBackend()
+	mock.transientFailures = 3
+	backend := NewCircuitBreakerBackend(mockLogger, mock, 3, 50*time.Millisecond)
+
+	// permanent errors don't count towards opening the circuit
+	mock.fail = true
+	_, err := backend.GetZone("missing.zone", true)
+	require.ErrorIs(t, err, errMockBackend)
+	mock.fail = false
+
+	for range 3 {
+		_, err := backend.ListZones()
+		require.ErrorIs(t, err, errMockTransient)
+	}
+	require.Equal(t, CircuitOpen, backend.State())
+
+	_, err = backend.ListZones()
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, 3, mock.calls[MethodListZones])
+
+	// a failed trial call reopens the circuit
+	mock.transientFailures = 1
+	time.Sleep(60 * time.Millisecond)
+	_, err = backend.ListZones()
+	require.ErrorIs(t, err, errMockTransient)
+	require.Equal(t, CircuitOpen, backend.State())
+
+	// a successful trial call closes it
+	time.Sleep(60 * time.Millisecond)
+	_, err = backend.ListZones()
+	require.NoError(t, err)
+	require.Equal(t, CircuitClosed, backend.State())
+	require.Equal(t, 5, mock.calls[MethodListZones])
+}
+
+func TestCircuitBreakerBackendTrial(t *testing.T) {
+	backend := NewCircuitBreakerBackend(mockLogger, newMockBackend(), 1, 0)
+
+	// a slow call is started while the circuit is closed
+	ok, slowTrial := backend.allow()
+	require.True(t, ok)
+	require.False(t, slowTrial)
+
+	ok, trial := backend.allow()
+	require.True(t, ok)
+	backend.done(trial, errMockTransient)
+	require.Equal(t, CircuitOpen, backend.State())
+
+	ok, trial = backend.allow()
+	require.True(t, ok)
+	require.True(t, trial)
+	require.Equal(t, CircuitHalfOpen, backend.State())
+
+	// the slow call finishing doesn't end the trial
+	backend.done(slowTrial, nil)
+	require.Equal(t, CircuitHalfOpen, backend.State())
+	ok, _ = backend.allow()
+	require.False(t, ok)
+
+	backend.done(trial, nil)
+	require.Equal(t, CircuitClosed, backend.State())
+}
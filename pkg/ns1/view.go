@@ -0,0 +1,126 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+// GlobalNetworkID is the ID of the NS1 Managed DNS global network, which most
+// zones are served on.
+const GlobalNetworkID = 0
+
+// ViewOptions configures how zones are associated with NS1 DNS views, and
+// which zones are kept based on their views and networks.
+type ViewOptions struct {
+	// Enabled looks up the views of each zone from the NS1 API.
+	Enabled bool
+	// ViewWhitelist is optional. When set, only zones in at least one
+	// view matching it are kept. Requires Enabled.
+	ViewWhitelist *regexp.Regexp
+	// NetworkWhitelist is optional. When set, only zones served on at
+	// least one of these networks are kept.
+	NetworkWhitelist []int
+}
+
+// JoinNetworkIDs returns network IDs as a sorted, comma separated string.
+func JoinNetworkIDs(networkIDs []int) string {
+	sorted := slices.Sorted(slices.Values(networkIDs))
+	ids := make([]string, len(sorted))
+	for i, id := range sorted {
+		ids[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+// mergeNetworkIDs returns the sorted union of the networks of two zones with
+// the same name. Zones without networks are served on the global network.
+func mergeNetworkIDs(a, b []int) []int {
+	if len(a) == 0 {
+		a = []int{GlobalNetworkID}
+	}
+	if len(b) == 0 {
+		b = []int{GlobalNetworkID}
+	}
+
+	merged := slices.Concat(a, b)
+	slices.Sort(merged)
+
+	return slices.Compact(merged)
+}
+
+// mergeViews returns the sorted union of the views of two zones with the same
+// name.
+func mergeViews(a, b []string) []string {
+	merged := slices.Concat(a, b)
+	slices.Sort(merged)
+
+	return slices.Compact(merged)
+}
+
+// zoneViews returns the sorted names of the views that contain a zone. Views
+// reference zones by name, so a view only contains a zone with that name if
+// they share a network, or if either doesn't list any networks.
+func zoneViews(views []*dns.View, zone string, networkIDs []int) []string {
+	var names []string
+	for _, view := range views {
+		if !slices.Contains(view.Zones, zone) {
+			continue
+		}
+
+		if len(view.Networks) > 0 && len(networkIDs) > 0 && !slices.ContainsFunc(view.Networks, func(id int) bool {
+			return slices.Contains(networkIDs, id)
+		}) {
+			continue
+		}
+
+		names = append(names, view.Name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// matchNetworks returns whether a zone served on the provided networks is
+// kept by the options' network whitelist. Zones without networks are served
+// on the global network.
+func (o ViewOptions) matchNetworks(networkIDs []int) bool {
+	if len(o.NetworkWhitelist) == 0 {
+		return true
+	}
+	if len(networkIDs) == 0 {
+		networkIDs = []int{GlobalNetworkID}
+	}
+
+	return slices.ContainsFunc(networkIDs, func(id int) bool {
+		return slices.Contains(o.NetworkWhitelist, id)
+	})
+}
+
+// matchViews returns whether a zone in the provided views is kept by the
+// options' view whitelist.
+func (o ViewOptions) matchViews(views []string) bool {
+	if o.ViewWhitelist == nil || o.ViewWhitelist.String() == "" {
+		return true
+	}
+
+	return slices.ContainsFunc(views, o.ViewWhitelist.MatchString)
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ns1
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+func TestMergeNetworkIDs(t *testing.T) {
+	tests := map[string]struct {
+		a, b []int
+		want []int
+	}{
+		"same":            {a: []int{5}, b: []int{5}, want: []int{5}},
+		"different":       {a: []int{7}, b: []int{0, 5}, want: []int{0, 5, 7}},
+		"implicit_global": {a: nil, b: []int{5}, want: []int{0, 5}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, mergeNetworkIDs(tc.a, tc.b))
+		})
+	}
+}
+
+func TestZoneViews(t *testing.T) {
+	views := []*dns.View{
+		{Name: "internal", Zones: []string{"foo.bar"}, Networks: []int{5}},
+		{Name: "external", Zones: []string{"foo.bar", "keep.me"}},
+		{Name: "global", Zones: []string{"foo.bar"}, Networks: []int{0}},
+	}
+
+	require.Equal(t, []string{"external", "internal"}, zoneViews(views, "foo.bar", []int{5}))
+	require.Equal(t, []string{"external", "global"}, zoneViews(views, "foo.bar", []int{0}))
+	require.Equal(t, []string{"external", "global", "internal"}, zoneViews(views, "foo.bar", nil))
+	require.Equal(t, []string{"external"}, zoneViews(views, "keep.me", []int{0}))
+	require.Empty(t, zoneViews(views, "drop.me", nil))
+}
+
+// viewBackend is a Backend with zones on multiple networks, in multiple views.
+type viewBackend struct {
+	*mockBackend
+	zones []*dns.Zone
+	views []*dns.View
+}
+
+func (b *viewBackend) ListZones() ([]*dns.Zone, error) {
+	return b.zones, nil
+}
+
+func (b *viewBackend) ListViews() ([]*dns.View, error) {
+	return b.views, b.count(MethodListViews)
+}
+
+func (b *viewBackend) GetZone(zone string, _ bool) (*dns.Zone, error) {
+	for _, z := range b.zones {
+		if z.Zone == zone {
+			return z, nil
+		}
+	}
+
+	return nil, errMockBackend
+}
+
+func TestRefreshZoneDataViews(t *testing.T) {
+	backend := &viewBackend{
+		mockBackend: newMockBackend(),
+		zones: []*dns.Zone{
+			{Zone: "foo.bar", NetworkIDs: []int{0}},
+			{Zone: "foo.bar", NetworkIDs: []int{5}},
+			{Zone: "keep.me"},
+		},
+		views: []*dns.View{
+			{Name: "internal", Zones: []string{"foo.bar"}, Networks: []int{5}},
+			{Name: "external", Zones: []string{"foo.bar", "keep.me"}, Networks: []int{0}},
+		},
+	}
+
+	tests := map[string]struct {
+		views        ViewOptions
+		wantViews    map[string][]string
+		wantNetworks map[string][]int
+	}{
+		"disabled": {
+			wantViews:    map[string][]string{"foo.bar": nil, "keep.me": nil},
+			wantNetworks: map[string][]int{"foo.bar": {0, 5}, "keep.me": nil},
+		},
+		"enabled": {
+			views:        ViewOptions{Enabled: true},
+			wantViews:    map[string][]string{"foo.bar": {"external", "internal"}, "keep.me": {"external"}},
+			wantNetworks: map[string][]int{"foo.bar": {0, 5}, "keep.me": nil},
+		},
+		"view_whitelist": {
+			views:        ViewOptions{Enabled: true, ViewWhitelist: regexp.MustCompile("^internal$")},
+			wantViews:    map[string][]string{"foo.bar": {"internal"}},
+			wantNetworks: map[string][]int{"foo.bar": {5}},
+		},
+		"network_whitelist": {
+			views:        ViewOptions{NetworkWhitelist: []int{0}},
+			wantViews:    map[string][]string{"foo.bar": nil, "keep.me": nil},
+			wantNetworks: map[string][]int{"foo.bar": {0}, "keep.me": nil},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			listViewsBefore := backend.calls[MethodListViews]
+
+			got, err := RefreshZoneData(mockLogger, backend, true, nil, nil, tc.views, nil)
+			require.NoError(t, err)
+			require.Len(t, got, len(tc.wantViews))
+			for key, wantViews := range tc.wantViews {
+				require.Contains(t, got, key)
+				require.Equal(t, wantViews, got[key].Views, key)
+				require.Equal(t, tc.wantNetworks[key], got[key].NetworkIDs, key)
+			}
+
+			// views are only listed when enabled
+			wantCalls := listViewsBefore
+			if tc.views.Enabled {
+				wantCalls++
+			}
+			require.Equal(t, wantCalls, backend.calls[MethodListViews])
+		})
+	}
+
+	// refreshes fail when views can't be listed, so that zones aren't
+	// filtered against an empty view list
+	failing := &viewBackend{mockBackend: newMockBackend(), zones: backend.zones}
+	failing.fail = true
+	_, err := RefreshZoneData(mockLogger, failing, true, nil, nil, ViewOptions{Enabled: true}, nil)
+	require.ErrorIs(t, err, errMockBackend)
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+const (
+	ns1AnswerLabelChain = ns1Label + "answer_chain"
+
+	// maxAliasChainLength is the maximum number of domains in a
+	// CNAME/ALIAS chain, including the domain of the record the chain
+	// starts at and the domain of the address record it ends at.
+	maxAliasChainLength = 16
+)
+
+var (
+	aliasRecordTypes   = []string{"CNAME", "ALIAS"}
+	addressRecordTypes = []string{"A", "AAAA"}
+)
+
+// targetAnswer is an answer that targets are created for. For answers that a
+// CNAME or ALIAS record was expanded into, chain holds the domains followed
+// to reach the answer.
+type targetAnswer struct {
+	answer *dns.Answer
+	chain  []string
+}
+
+// isAliasRecord returns whether a record's answers are hostnames that can be
+// expanded into the answers of address records.
+func isAliasRecord(record *dns.Record) bool {
+	return slices.Contains(aliasRecordTypes, strings.ToUpper(record.Type))
+}
+
+// expandAliases follows the answers of a CNAME or ALIAS record through the
+// index, across CNAME and ALIAS records of any cached zone, to the A and AAAA
+// records they end at, and returns the answers of those records. Links of the
+// records in a chain are resolved. Hostnames that aren't in the index, such as
+// names outside of the NS1 account, and chains that loop or are too long, are
+// logged and skipped, since they can't be resolved without querying DNS.
+func (i recordIndex) expandAliases(logger *slog.Logger, record *dns.Record) []targetAnswer {
+	var (
+		expanded []targetAnswer
+		follow   func(r *dns.Record, chain []string)
+	)
+
+	follow = func(r *dns.Record, chain []string) {
+		for _, answer := range r.Answers {
+			if len(answer.Rdata) == 0 {
+				continue
+			}
+
+			host := normalizeDomain(answer.Rdata[0])
+			if slices.Contains(chain, host) {
+				logger.Warn("Skipping CNAME/ALIAS answer, loop detected", "record_domain", record.Domain, "record_type", record.Type, "chain", strings.Join(chain, ",")+","+host)
+				continue
+			}
+			if len(chain) >= maxAliasChainLength {
+				logger.Warn("Skipping CNAME/ALIAS answer, chain too long", "record_domain", record.Domain, "record_type", record.Type, "chain", strings.Join(chain, ","))
+				continue
+			}
+			hostChain := append(slices.Clip(chain), host)
+
+			if addresses := i.lookup(logger, host, addressRecordTypes); len(addresses) > 0 {
+				for _, address := range addresses {
+					for _, a := range address.Answers {
+						if len(a.Rdata) > 0 {
+							expanded = append(expanded, targetAnswer{answer: a, chain: hostChain})
+						}
+					}
+				}
+				continue
+			}
+
+			aliases := i.lookup(logger, host, aliasRecordTypes)
+			if len(aliases) == 0 {
+				logger.Debug("Skipping CNAME/ALIAS answer, hostname not found in record cache", "record_domain", record.Domain, "record_type", record.Type, "hostname", host)
+				continue
+			}
+			for _, alias := range aliases {
+				follow(alias, hostChain)
+			}
+		}
+	}
+	follow(record, []string{normalizeDomain(record.Domain)})
+
+	return expanded
+}
+
+// lookup returns the records of a domain with any of the provided types, with
+// their links resolved. Records whose links can't be resolved are logged and
+// skipped.
+func (i recordIndex) lookup(logger *slog.Logger, domain string, types []string) []*dns.Record {
+	var records []*dns.Record
+	for _, t := range types {
+		record, ok := i[recordIndexKey(domain, t)]
+		if !ok {
+			continue
+		}
+
+		resolved, err := i.resolveLink(record)
+		if err != nil {
+			logger.Warn("Failed to resolve linked record", "err", err, "record_domain", record.Domain, "record_type", record.Type, "record_link", record.Link)
+			continue
+		}
+		records = append(records, resolved)
+	}
+
+	return records
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+var mockAliasRecords = []*dns.Record{
+	{Zone: "foo.bar", Domain: "foo.bar", Type: "ALIAS", Answers: []*dns.Answer{{ID: "alias", Rdata: []string{"www.foo.bar"}}}},
+	{Zone: "foo.bar", Domain: "www.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "www", Rdata: []string{"lb.foo.bar."}}}},
+	{Zone: "foo.bar", Domain: "lb.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "lb", Rdata: []string{"LB.keep.me."}}}},
+	{Zone: "keep.me", Domain: "lb.keep.me", Type: "A", Answers: []*dns.Answer{
+		{ID: "v4-0", Rdata: []string{"1.2.3.4"}},
+		{ID: "v4-1", Rdata: []string{"5.6.7.8"}},
+	}},
+	{Zone: "keep.me", Domain: "lb.keep.me", Type: "AAAA", Answers: []*dns.Answer{{ID: "v6-0", Rdata: []string{"dead::beef"}}}},
+	{Zone: "foo.bar", Domain: "linked.foo.bar", Type: "A", Link: "lb.keep.me"},
+	{Zone: "foo.bar", Domain: "to-linked.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "to-linked", Rdata: []string{"linked.foo.bar"}}}},
+	{Zone: "foo.bar", Domain: "external.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "external", Rdata: []string{"example.com."}}}},
+	{Zone: "foo.bar", Domain: "loop1.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "loop1", Rdata: []string{"loop2.foo.bar"}}}},
+	{Zone: "foo.bar", Domain: "loop2.foo.bar", Type: "CNAME", Answers: []*dns.Answer{{ID: "loop2", Rdata: []string{"loop1.foo.bar"}}}},
+}
+
+// answerIDs returns the IDs and chains of expanded answers, for comparison.
+func answerIDs(answers []targetAnswer) []string {
+	var ids []string
+	for _, a := range answers {
+		ids = append(ids, fmt.Sprintf("%s %v", a.answer.ID, a.chain))
+	}
+
+	return ids
+}
+
+func TestExpandAliases(t *testing.T) {
+	index := newRecordIndex(mockAliasRecords)
+
+	tests := map[string]struct {
+		record *dns.Record
+		want   []string
+	}{
+		"cname_chain_across_zones": {
+			record: mockAliasRecords[1],
+			want: []string{
+				"v4-0 [www.foo.bar lb.foo.bar lb.keep.me]",
+				"v4-1 [www.foo.bar lb.foo.bar lb.keep.me]",
+				"v6-0 [www.foo.bar lb.foo.bar lb.keep.me]",
+			},
+		},
+		"alias_to_cname": {
+			record: mockAliasRecords[0],
+			want: []string{
+				"v4-0 [foo.bar www.foo.bar lb.foo.bar lb.keep.me]",
+				"v4-1 [foo.bar www.foo.bar lb.foo.bar lb.keep.me]",
+				"v6-0 [foo.bar www.foo.bar lb.foo.bar lb.keep.me]",
+			},
+		},
+		"linked_address_record": {
+			record: mockAliasRecords[6],
+			want: []string{
+				"v4-0 [to-linked.foo.bar linked.foo.bar]",
+				"v4-1 [to-linked.foo.bar linked.foo.bar]",
+			},
+		},
+		"external": {record: mockAliasRecords[7]},
+		"loop":     {record: mockAliasRecords[8]},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, answerIDs(index.expandAliases(mockLogger, tc.record)))
+		})
+	}
+}
+
+func TestExpandAliasesChainLength(t *testing.T) {
+	var records []*dns.Record
+	for n := range maxAliasChainLength + 1 {
+		records = append(records, &dns.Record{
+			Domain:  fmt.Sprintf("%d.foo.bar", n),
+			Type:    "CNAME",
+			Answers: []*dns.Answer{{Rdata: []string{fmt.Sprintf("%d.foo.bar", n+1)}}},
+		})
+	}
+	records = append(records, &dns.Record{
+		Domain:  fmt.Sprintf("%d.foo.bar", maxAliasChainLength+1),
+		Type:    "A",
+		Answers: []*dns.Answer{{Rdata: []string{"1.2.3.4"}}},
+	})
+	index := newRecordIndex(records)
+
+	require.Empty(t, index.expandAliases(mockLogger, records[0]))
+	require.Len(t, index.expandAliases(mockLogger, records[2]), 1)
+}
+
+func TestRecordTargetsExpandAliases(t *testing.T) {
+	index := newRecordIndex(mockAliasRecords)
+	record := mockAliasRecords[1]
+
+	// without expansion, the answers are hostnames
+	targets := recordTargets(mockLogger, TargetOptions{Mode: TargetModeAnswer}, nil, index, record)
+	require.Len(t, targets, 1)
+	require.Equal(t, []string{"lb.foo.bar."}, targets[0].Targets)
+	require.NotContains(t, targets[0].Labels, ns1AnswerLabelChain)
+
+	targets = recordTargets(mockLogger, TargetOptions{Mode: TargetModeRdata, ExpandAliases: true}, nil, index, record)
+	require.Len(t, targets, 3)
+
+	var got []string
+	for i, target := range targets {
+		got = append(got, target.Targets...)
+
+		// targets keep the labels of the CNAME record, with the labels
+		// of the answers it was expanded into
+		require.Equal(t, "www.foo.bar", string(target.Labels[ns1RecordLabelDomain]))
+		require.Equal(t, "CNAME", string(target.Labels[ns1RecordLabelType]))
+		require.Equal(t, fmt.Sprint(i), string(target.Labels[ns1AnswerLabelIndex]))
+		require.Equal(t, ",www.foo.bar,lb.foo.bar,lb.keep.me,", string(target.Labels[ns1AnswerLabelChain]))
+	}
+	require.Equal(t, []string{"1.2.3.4", "5.6.7.8", "dead::beef"}, got)
+
+	// record targets aren't expanded
+	targets = recordTargets(mockLogger, TargetOptions{ExpandAliases: true}, nil, index, record)
+	require.Len(t, targets, 1)
+	require.Equal(t, []string{"www.foo.bar-CNAME"}, targets[0].Targets)
+}
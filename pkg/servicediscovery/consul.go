@@ -0,0 +1,322 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+const (
+	consulPathPrefix      = "/v1/"
+	consulServicesPath    = consulPathPrefix + "catalog/services"
+	consulServicePath     = consulPathPrefix + "catalog/service/"
+	consulAgentSelfPath   = consulPathPrefix + "agent/self"
+	consulDefaultWait     = 5 * time.Minute
+	consulMaxWait         = 10 * time.Minute
+	consulWriteTimeout    = 30 * time.Second
+	consulDefaultNodeName = "ns1_exporter"
+)
+
+// consulCatalogService is an entry of the response of Consul's
+// `/v1/catalog/service/<name>` endpoint, with the subset of fields that
+// consumers such as Prometheus's consul_sd use.
+type consulCatalogService struct {
+	ID              string            `json:"ID"`
+	Node            string            `json:"Node"`
+	Address         string            `json:"Address"`
+	Datacenter      string            `json:"Datacenter"`
+	TaggedAddresses map[string]string `json:"TaggedAddresses"`
+	NodeMeta        map[string]string `json:"NodeMeta"`
+	ServiceID       string            `json:"ServiceID"`
+	ServiceName     string            `json:"ServiceName"`
+	ServiceTags     []string          `json:"ServiceTags"`
+	ServiceAddress  string            `json:"ServiceAddress"`
+	ServiceMeta     map[string]string `json:"ServiceMeta"`
+	ServicePort     int               `json:"ServicePort"`
+	CreateIndex     uint64            `json:"CreateIndex"`
+	ModifyIndex     uint64            `json:"ModifyIndex"`
+}
+
+// ConsulCatalog serves a read-only subset of the Consul HTTP API, for tools
+// that can consume the Consul catalog but not Prometheus HTTP SD. Services are
+// derived from the A and AAAA records cached by the worker: each record domain
+// is a service, and each answer of the domain's A and AAAA records is an
+// instance of it, with the answer's first rdata field as its address.
+//
+// The following endpoints are served:
+//
+//   - /v1/catalog/services
+//   - /v1/catalog/service/<name>
+//   - /v1/agent/self, with the datacenter and node name only
+//
+// The catalog endpoints support blocking queries with the `index` and `wait`
+// query parameters, where the index is derived from the generation of the
+// worker's targets. Blocking queries also return when the catalog is closed.
+type ConsulCatalog struct {
+	// Datacenter is the datacenter reported for all services.
+	Datacenter string
+	// ServicePort is the port reported for all service instances.
+	ServicePort int
+
+	worker    *Worker
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConsulCatalog creates a ConsulCatalog serving the provided worker's
+// records.
+func NewConsulCatalog(worker *Worker, datacenter string, servicePort int) *ConsulCatalog {
+	return &ConsulCatalog{
+		Datacenter:  datacenter,
+		ServicePort: servicePort,
+		worker:      worker,
+		done:        make(chan struct{}),
+	}
+}
+
+// Close ends all blocking queries, which return the current catalog, and
+// makes later blocking queries return immediately. http.Server.Shutdown
+// doesn't cancel the contexts of active requests, so Close should be
+// registered with http.Server.RegisterOnShutdown, for shutdowns not to wait
+// for blocking queries to time out.
+func (c *ConsulCatalog) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// consulIndex converts a target generation into a Consul index, which must
+// always be greater than 0.
+func consulIndex(generation int) uint64 {
+	return uint64(generation) + 1 //nolint:gosec // generations are never negative
+}
+
+// isConsulAddressRecord returns whether a record's answers are addresses that
+// can be used as service instances.
+func isConsulAddressRecord(record *dns.Record) bool {
+	return record.Type == "A" || record.Type == "AAAA"
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (c *ConsulCatalog) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case req.URL.Path == consulServicesPath:
+		c.serveCatalog(writer, req, c.services)
+	case strings.HasPrefix(req.URL.Path, consulServicePath) && len(req.URL.Path) > len(consulServicePath):
+		name := strings.TrimPrefix(req.URL.Path, consulServicePath)
+		tags := req.URL.Query()["tag"]
+		c.serveCatalog(writer, req, func(records []*dns.Record, index uint64) any {
+			return c.service(records, index, name, tags)
+		})
+	case req.URL.Path == consulAgentSelfPath:
+		c.writeJSON(writer, map[string]any{
+			"Config": map[string]string{
+				"Datacenter": c.Datacenter,
+				"NodeName":   consulDefaultNodeName,
+			},
+		})
+	default:
+		http.NotFound(writer, req)
+	}
+}
+
+// serveCatalog serves a catalog endpoint, blocking first if the request is a
+// blocking query for the current index.
+func (c *ConsulCatalog) serveCatalog(writer http.ResponseWriter, req *http.Request, build func(records []*dns.Record, index uint64) any) {
+	query := req.URL.Query()
+
+	if rawIndex := query.Get("index"); rawIndex != "" {
+		index, err := strconv.ParseUint(rawIndex, 10, 64)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("invalid index %q", rawIndex), http.StatusBadRequest)
+			return
+		}
+
+		wait := consulDefaultWait
+		if rawWait := query.Get("wait"); rawWait != "" {
+			wait, err = parseConsulWait(rawWait)
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		generation, changed := c.worker.watch()
+		if index == consulIndex(generation) {
+			// the HTTP server's write timeout is shorter than most
+			// blocking queries, so extend it for this request
+			if err := http.NewResponseController(writer).SetWriteDeadline(time.Now().Add(wait + consulWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				c.worker.logger.Debug("Failed to extend write deadline for blocking query", "err", err)
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-changed:
+			case <-timer.C:
+			case <-req.Context().Done():
+			case <-c.done:
+			}
+			timer.Stop()
+		}
+	}
+
+	set := c.worker.current()
+	index := consulIndex(set.generation)
+
+	writer.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	writer.Header().Set("X-Consul-KnownLeader", "true")
+	writer.Header().Set("X-Consul-LastContact", "0")
+	c.writeJSON(writer, build(set.records, index))
+}
+
+// parseConsulWait parses the wait time of a blocking query, which is a Go
+// duration or a number of seconds, capped at 10 minutes like Consul does.
+func parseConsulWait(raw string) (time.Duration, error) {
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid wait %q", raw)
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	return min(wait, consulMaxWait), nil
+}
+
+func (c *ConsulCatalog) writeJSON(writer http.ResponseWriter, data any) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		c.worker.logger.Error("Failed to encode Consul API response", "err", err)
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	if bytesWritten, err := writer.Write(buf); err != nil {
+		c.worker.logger.Error("Failed to write full HTTP response", "err", err, "bytes", bytesWritten)
+	}
+}
+
+// services returns the names of all services, mapped to their tags.
+func (c *ConsulCatalog) services(records []*dns.Record, _ uint64) any {
+	services := make(map[string][]string)
+	for _, record := range records {
+		if !isConsulAddressRecord(record) {
+			continue
+		}
+
+		tags := services[record.Domain]
+		for _, tag := range consulServiceTags(record) {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		slices.Sort(tags)
+		services[record.Domain] = tags
+	}
+
+	return services
+}
+
+// service returns the instances of the service with the provided name, with
+// all of the provided tags.
+func (c *ConsulCatalog) service(records []*dns.Record, index uint64, name string, tags []string) any {
+	entries := []*consulCatalogService{}
+	for _, record := range records {
+		if record.Domain != name || !isConsulAddressRecord(record) {
+			continue
+		}
+
+		serviceTags := consulServiceTags(record)
+		if !containsAll(serviceTags, tags) {
+			continue
+		}
+
+		for _, answer := range record.Answers {
+			if len(answer.Rdata) == 0 {
+				continue
+			}
+			address := answer.Rdata[0]
+
+			meta := make(map[string]string)
+			for k, v := range metaAsStringMap(answer.Meta) {
+				meta[sanitizeLabelName(k)] = v
+			}
+			meta["ns1_zone"] = record.Zone
+			meta["ns1_record_type"] = record.Type
+			meta["ns1_answer_id"] = answer.ID
+			meta["ns1_region"] = answer.RegionName
+
+			entries = append(entries, &consulCatalogService{
+				ID:              answer.ID,
+				Node:            record.Zone,
+				Address:         address,
+				Datacenter:      c.Datacenter,
+				TaggedAddresses: map[string]string{},
+				NodeMeta:        map[string]string{},
+				ServiceID:       record.Domain + "-" + record.Type + "-" + answer.ID,
+				ServiceName:     record.Domain,
+				ServiceTags:     serviceTags,
+				ServiceAddress:  address,
+				ServiceMeta:     meta,
+				ServicePort:     c.ServicePort,
+				CreateIndex:     index,
+				ModifyIndex:     index,
+			})
+		}
+	}
+
+	return entries
+}
+
+// consulServiceTags returns the tags of the service instances of a record:
+// its type, and its NS1 tags as `key=value`.
+func consulServiceTags(record *dns.Record) []string {
+	tags := []string{record.Type}
+	for _, key := range slices.Sorted(maps.Keys(record.Tags)) {
+		tags = append(tags, key+"="+record.Tags[key])
+	}
+
+	return tags
+}
+
+func containsAll(values, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(values, w) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newMockConsulCatalog(t *testing.T) (*Worker, *ConsulCatalog) {
+	t.Helper()
+
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	return worker, NewConsulCatalog(worker, "dc1", 9100)
+}
+
+func serveConsul(catalog *ConsulCatalog, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	catalog.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+
+	return rec
+}
+
+func TestConsulCatalogServices(t *testing.T) {
+	_, catalog := newMockConsulCatalog(t)
+
+	rec := serveConsul(catalog, http.MethodGet, "/v1/catalog/services")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("X-Consul-Index"))
+	require.JSONEq(t, `{"test.foo.bar": ["A", "AAAA"]}`, rec.Body.String())
+}
+
+func TestConsulCatalogService(t *testing.T) {
+	_, catalog := newMockConsulCatalog(t)
+
+	tests := map[string]struct {
+		target    string
+		wantAddrs []string
+	}{
+		"all":          {target: "/v1/catalog/service/test.foo.bar", wantAddrs: []string{"1.2.3.4", "dead::beef"}},
+		"tag":          {target: "/v1/catalog/service/test.foo.bar?tag=AAAA", wantAddrs: []string{"dead::beef"}},
+		"unknown_tag":  {target: "/v1/catalog/service/test.foo.bar?tag=MX", wantAddrs: []string{}},
+		"unknown_name": {target: "/v1/catalog/service/nope.foo.bar", wantAddrs: []string{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serveConsul(catalog, http.MethodGet, tc.target)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var entries []*consulCatalogService
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+			addrs := []string{}
+			for _, entry := range entries {
+				addrs = append(addrs, entry.ServiceAddress)
+			}
+			require.Equal(t, tc.wantAddrs, addrs)
+		})
+	}
+
+	rec := serveConsul(catalog, http.MethodGet, "/v1/catalog/service/test.foo.bar?tag=A")
+	var entries []*consulCatalogService
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, &consulCatalogService{
+		ID:              "mockARecordAnswerID",
+		Node:            "foo.bar",
+		Address:         "1.2.3.4",
+		Datacenter:      "dc1",
+		TaggedAddresses: map[string]string{},
+		NodeMeta:        map[string]string{},
+		ServiceID:       "test.foo.bar-A-mockARecordAnswerID",
+		ServiceName:     "test.foo.bar",
+		ServiceTags:     []string{"A"},
+		ServiceAddress:  "1.2.3.4",
+		ServiceMeta: map[string]string{
+			"up":              "1",
+			"ns1_zone":        "foo.bar",
+			"ns1_record_type": "A",
+			"ns1_answer_id":   "mockARecordAnswerID",
+			"ns1_region":      "",
+		},
+		ServicePort: 9100,
+		CreateIndex: 2,
+		ModifyIndex: 2,
+	}, entries[0])
+}
+
+func TestConsulCatalogErrors(t *testing.T) {
+	_, catalog := newMockConsulCatalog(t)
+
+	tests := map[string]struct {
+		method string
+		target string
+		want   int
+	}{
+		"method":      {method: http.MethodPost, target: "/v1/catalog/services", want: http.StatusMethodNotAllowed},
+		"unknown":     {method: http.MethodGet, target: "/v1/kv/foo", want: http.StatusNotFound},
+		"no_service":  {method: http.MethodGet, target: "/v1/catalog/service/", want: http.StatusNotFound},
+		"bad_index":   {method: http.MethodGet, target: "/v1/catalog/services?index=abc", want: http.StatusBadRequest},
+		"bad_wait":    {method: http.MethodGet, target: "/v1/catalog/services?index=1&wait=soon", want: http.StatusBadRequest},
+		"agent_self":  {method: http.MethodGet, target: "/v1/agent/self", want: http.StatusOK},
+		"stale_index": {method: http.MethodGet, target: "/v1/catalog/services?index=1&wait=1h", want: http.StatusOK},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, serveConsul(catalog, tc.method, tc.target).Code)
+		})
+	}
+}
+
+func TestParseConsulWait(t *testing.T) {
+	wait, err := parseConsulWait("30s")
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, wait)
+
+	wait, err = parseConsulWait("30")
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, wait)
+
+	wait, err = parseConsulWait("1h")
+	require.NoError(t, err)
+	require.Equal(t, consulMaxWait, wait)
+
+	_, err = parseConsulWait("soon")
+	require.Error(t, err)
+}
+
+func TestConsulCatalogBlockingQuery(t *testing.T) {
+	worker, catalog := newMockConsulCatalog(t)
+
+	// a blocking query for the current index times out with the same index
+	start := time.Now()
+	rec := serveConsul(catalog, http.MethodGet, "/v1/catalog/services?index=2&wait=50ms")
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Equal(t, "2", rec.Header().Get("X-Consul-Index"))
+
+	// and returns as soon as the targets are refreshed
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serveConsul(catalog, http.MethodGet, "/v1/catalog/service/test.foo.bar?index=2&wait=1m")
+	}()
+
+	// give the query time to block; if it hasn't yet, it sees the new
+	// index and returns immediately, with the same result
+	time.Sleep(50 * time.Millisecond)
+	worker.recordCache = mockDnsRecordCache[1:]
+	worker.RefreshPrometheusTargetData()
+
+	select {
+	case rec := <-done:
+		require.Equal(t, "3", rec.Header().Get("X-Consul-Index"))
+		var entries []*consulCatalogService
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+		require.Len(t, entries, 1)
+		require.Equal(t, "dead::beef", entries[0].ServiceAddress)
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "blocking query didn't return after refresh")
+	}
+}
+
+func TestConsulCatalogBlockingQueryShutdown(t *testing.T) {
+	_, catalog := newMockConsulCatalog(t)
+
+	server := httptest.NewUnstartedServer(catalog)
+	server.Config.RegisterOnShutdown(catalog.Close)
+	server.Start()
+	defer server.Close()
+
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := http.Get(server.URL + "/v1/catalog/services?index=2&wait=1m") //nolint:noctx // the server ends the request
+		if err != nil {
+			close(done)
+			return
+		}
+		done <- resp
+	}()
+
+	// give the query time to block; if it hasn't yet, it's still served
+	// before the server shuts down, and returns immediately
+	time.Sleep(50 * time.Millisecond)
+
+	// shutting down waits for active requests, so blocking queries must
+	// return as soon as shutdown starts
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, server.Config.Shutdown(ctx))
+
+	resp, ok := <-done
+	require.True(t, ok, "blocking query failed")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("X-Consul-Index"))
+
+	// later blocking queries return immediately
+	start := time.Now()
+	rec := serveConsul(catalog, http.MethodGet, "/v1/catalog/services?index=2&wait=1m")
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, "2", rec.Header().Get("X-Consul-Index"))
+}
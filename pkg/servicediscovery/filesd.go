@@ -0,0 +1,130 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tjhop/ns1_exporter/pkg/storage"
+)
+
+// fileSDName is the name of the file_sd file with the worker's targets. The
+// targets of each job are written to fileSDName + "_" + job name.
+const fileSDName = "sd"
+
+// FileSDFormat is the format of file_sd files.
+type FileSDFormat string
+
+const (
+	FileSDFormatJSON FileSDFormat = "json"
+	FileSDFormatYAML FileSDFormat = "yaml"
+)
+
+// ParseFileSDFormat parses a file_sd format, returning an error for unknown
+// formats.
+func ParseFileSDFormat(format string) (FileSDFormat, error) {
+	switch f := FileSDFormat(format); f {
+	case FileSDFormatJSON, FileSDFormatYAML:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown file_sd format %q", format)
+	}
+}
+
+// FileSDWriter writes target groups to files in a directory, in the format of
+// Prometheus's file_sd_configs, for Prometheus servers that can't reach the
+// `/sd` endpoint but share a filesystem with the exporter. Files are written
+// atomically, and only when their content changes, so that Prometheus never
+// reads a partially written file or reloads unchanged targets.
+type FileSDWriter struct {
+	logger *slog.Logger
+	dir    string
+	format FileSDFormat
+
+	mu      sync.Mutex
+	written map[string][]byte
+}
+
+// NewFileSDWriter creates a FileSDWriter that writes files in the provided
+// format to the provided directory, creating it if needed.
+func NewFileSDWriter(logger *slog.Logger, dir string, format FileSDFormat) (*FileSDWriter, error) {
+	if _, err := ParseFileSDFormat(string(format)); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create file_sd directory: %w", err)
+	}
+
+	return &FileSDWriter{
+		logger:  logger.With("component", "file_sd"),
+		dir:     dir,
+		format:  format,
+		written: make(map[string][]byte),
+	}, nil
+}
+
+// path returns the path of the file with the provided name.
+func (f *FileSDWriter) path(name string) string {
+	return filepath.Join(f.dir, name+"."+string(f.format))
+}
+
+// Write writes the provided targets to the file with the provided name, unless
+// the file was already written with the same content.
+func (f *FileSDWriter) Write(name string, targets []*HTTPSDTarget) error {
+	// write an empty list rather than null, so that the file is valid for
+	// Prometheus either way
+	if targets == nil {
+		targets = []*HTTPSDTarget{}
+	}
+
+	var (
+		buf []byte
+		err error
+	)
+	switch f.format {
+	case FileSDFormatYAML:
+		buf, err = yaml.Marshal(targets)
+	default:
+		buf, err = json.MarshalIndent(targets, "", "    ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode file_sd targets: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if prev, ok := f.written[name]; ok && bytes.Equal(prev, buf) {
+		return nil
+	}
+
+	path := f.path(name)
+	if err := storage.WriteFileAtomic(path, buf, 0o644); err != nil {
+		return fmt.Errorf("failed to write file_sd file: %w", err)
+	}
+	f.written[name] = buf
+	f.logger.Debug("Wrote file_sd file", "path", path, "num_targets", len(targets))
+
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseFileSDFormat(t *testing.T) {
+	format, err := ParseFileSDFormat("yaml")
+	require.NoError(t, err)
+	require.Equal(t, FileSDFormatYAML, format)
+
+	_, err = ParseFileSDFormat("toml")
+	require.Error(t, err)
+
+	_, err = NewFileSDWriter(mockLogger, t.TempDir(), "toml")
+	require.Error(t, err)
+}
+
+func TestFileSDWriter(t *testing.T) {
+	tests := map[string]struct {
+		format    FileSDFormat
+		unmarshal func([]byte, any) error
+	}{
+		"json": {format: FileSDFormatJSON, unmarshal: json.Unmarshal},
+		"yaml": {format: FileSDFormatYAML, unmarshal: yaml.Unmarshal},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "file_sd")
+			writer, err := NewFileSDWriter(mockLogger, dir, tc.format)
+			require.NoError(t, err)
+
+			path := filepath.Join(dir, "sd."+string(tc.format))
+			require.NoError(t, writer.Write("sd", mockSDTargetCache))
+
+			// the file can be read by Prometheus's file_sd
+			buf, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var groups []*targetgroup.Group
+			require.NoError(t, tc.unmarshal(buf, &groups))
+			require.Len(t, groups, len(mockSDTargetCache))
+			for i, group := range groups {
+				require.Equal(t, mockSDTargetCache[i].Targets[0], string(group.Targets[0]["__address__"]))
+				require.Equal(t, mockSDTargetCache[i].Labels, group.Labels)
+			}
+
+			// unchanged targets aren't written again
+			require.NoError(t, os.Remove(path))
+			require.NoError(t, writer.Write("sd", mockSDTargetCache))
+			require.NoFileExists(t, path)
+
+			// no targets are written as an empty list
+			require.NoError(t, writer.Write("sd", nil))
+			require.NoError(t, tc.unmarshal(mustReadFile(t, path), &groups))
+			require.Empty(t, groups)
+		})
+	}
+}
+
+func TestRefreshPrometheusTargetDataFileSD(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewFileSDWriter(mockLogger, dir, FileSDFormatJSON)
+	require.NoError(t, err)
+
+	jobs, err := ParseJobsConfig(mockLogger, []byte(`
+jobs:
+  - name: aaaa
+    types: [AAAA]
+`))
+	require.NoError(t, err)
+
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.FileSD = writer
+	worker.Jobs = jobs
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	var got []*HTTPSDTarget
+	require.NoError(t, json.Unmarshal(mustReadFile(t, filepath.Join(dir, "sd.json")), &got))
+	require.Equal(t, mockSDTargetCache, got)
+
+	require.NoError(t, json.Unmarshal(mustReadFile(t, filepath.Join(dir, "sd_aaaa.json")), &got))
+	require.Equal(t, mockSDTargetCache[1:], got)
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return buf
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+// sanitizeLabelName converts an NS1 tag or meta key into a valid Prometheus
+// label name by replacing all invalid characters with underscores.
+func sanitizeLabelName(name string) string {
+	var builder strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	return builder.String()
+}
+
+// infoLabels builds the label names and values of an info metric from a map
+// of keys to values, with each key sanitized and prefixed. Keys that collide
+// after sanitization are kept only once, using the first key in sorted order.
+func infoLabels(prefix string, values map[string]string, names, labelValues []string) ([]string, []string) {
+	keys := slices.Sorted(maps.Keys(values))
+
+	seen := make(map[string]struct{}, len(names)+len(keys))
+	for _, name := range names {
+		seen[name] = struct{}{}
+	}
+
+	for _, key := range keys {
+		name := prefix + sanitizeLabelName(key)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		names = append(names, name)
+		labelValues = append(labelValues, values[key])
+	}
+
+	return names, labelValues
+}
+
+func metaAsStringMap(meta *data.Meta) map[string]string {
+	if meta == nil {
+		return nil
+	}
+
+	metaMap := meta.StringMap()
+	values := make(map[string]string, len(metaMap))
+	for k, v := range metaMap {
+		values[k] = fmt.Sprintf("%v", v)
+	}
+
+	return values
+}
+
+// Describe implements the prometheus.Collector interface. The labels of the
+// info metrics depend on the tags and meta of each zone/record, so the worker
+// is an unchecked collector and doesn't describe any metrics up front.
+func (w *Worker) Describe(_ chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface. It exposes the tags
+// of each zone and record that the current targets were built from, and the
+// meta of each record, as info metrics that can be joined onto QPS metrics.
+func (w *Worker) Collect(ch chan<- prometheus.Metric) {
+	set := w.current()
+	for zName, zData := range set.zones {
+		names, values := infoLabels("tag_", zData.Tags, []string{"zone_name"}, []string{zName})
+		ch <- prometheus.MustNewConstMetric(metrics.NewZoneTagsInfoDesc(names), prometheus.GaugeValue, 1, values...)
+	}
+
+	for _, r := range set.records {
+		names, values := infoLabels("tag_", r.Tags, []string{"zone_name", "record_name", "record_type"}, []string{r.Zone, r.Domain, r.Type})
+		names, values = infoLabels("meta_", metaAsStringMap(r.Meta), names, values)
+		ch <- prometheus.MustNewConstMetric(metrics.NewRecordTagsInfoDesc(names), prometheus.GaugeValue, 1, values...)
+	}
+}
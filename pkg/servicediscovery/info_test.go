@@ -0,0 +1,83 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+func TestSanitizeLabelName(t *testing.T) {
+	tests := map[string]struct {
+		name string
+		want string
+	}{
+		"valid":         {name: "team", want: "team"},
+		"dash":          {name: "cost-center", want: "cost_center"},
+		"dots":          {name: "app.kubernetes.io/name", want: "app_kubernetes_io_name"},
+		"leading_digit": {name: "1team", want: "_team"},
+		"unicode":       {name: "équipe", want: "_quipe"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, sanitizeLabelName(tc.name))
+		})
+	}
+}
+
+func TestCollectTagsInfo(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.zoneCache = map[string]*ns1_internal.Zone{
+		"foo.bar": {Zone: "foo.bar", Tags: map[string]string{"team": "dns", "cost-center": "1234"}},
+		"keep.me": {Zone: "keep.me"},
+	}
+	worker.recordCache = []*dns.Record{
+		{
+			Zone:   "foo.bar",
+			Domain: "test.foo.bar",
+			Type:   "A",
+			Tags:   map[string]string{"team": "edge", "cost.center": "5678", "cost-center": "9999"},
+			Meta:   &data.Meta{Up: true, Note: "primary"},
+		},
+		{Zone: "foo.bar", Domain: "test.foo.bar", Type: "AAAA"},
+	}
+
+	// only the caches that targets were built from are collected
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(worker))
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader("")))
+	worker.RefreshPrometheusTargetData()
+
+	expected := `
+# HELP ns1_record_tags_info Tags and meta of the labeled NS1 record, for joining onto other metrics. Tag and meta keys are sanitized and prefixed with tag_ and meta_.
+# TYPE ns1_record_tags_info gauge
+ns1_record_tags_info{meta_note="primary",meta_up="1",record_name="test.foo.bar",record_type="A",tag_cost_center="9999",tag_team="edge",zone_name="foo.bar"} 1
+ns1_record_tags_info{record_name="test.foo.bar",record_type="AAAA",zone_name="foo.bar"} 1
+# HELP ns1_zone_tags_info Tags of the labeled NS1 zone, for joining onto other metrics. Tag keys are sanitized and prefixed with tag_.
+# TYPE ns1_zone_tags_info gauge
+ns1_zone_tags_info{tag_cost_center="1234",tag_team="dns",zone_name="foo.bar"} 1
+ns1_zone_tags_info{zone_name="keep.me"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
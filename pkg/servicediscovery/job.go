@@ -0,0 +1,232 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	"gopkg.in/yaml.v3"
+
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+var jobNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// JobsConfig is the format of the file loaded by LoadJobsConfig.
+type JobsConfig struct {
+	Jobs []*JobConfig `yaml:"jobs"`
+}
+
+// JobConfig configures a named SD job, which serves the targets of a subset
+// of the worker's cached records at `/sd/<name>`.
+type JobConfig struct {
+	Name string `yaml:"name"`
+	// Zones, Types, DomainRegex and Meta select the records that the job
+	// serves targets for, with the same semantics as the query parameters
+	// of the `/sd` endpoint.
+	Zones       []string          `yaml:"zones"`
+	Types       []string          `yaml:"types"`
+	DomainRegex string            `yaml:"domain_regex"`
+	Meta        map[string]string `yaml:"meta"`
+	// TargetMode, AddressTemplate, StructuredLabels, LinkMode and
+	// ExpandAliases configure the job's TargetOptions.
+	TargetMode       string `yaml:"target_mode"`
+	AddressTemplate  string `yaml:"address_template"`
+	StructuredLabels bool   `yaml:"structured_labels"`
+	LinkMode         string `yaml:"link_mode"`
+	ExpandAliases    bool   `yaml:"expand_aliases"`
+	// RefreshInterval is the minimum interval at which the job's targets
+	// are rebuilt from the worker's record cache. Default (0) rebuilds the
+	// targets whenever the record cache changes.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// LoadJobsConfig reads and parses SD jobs from a YAML file.
+func LoadJobsConfig(logger *slog.Logger, path string) ([]*Job, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SD jobs config: %w", err)
+	}
+
+	return ParseJobsConfig(logger, buf)
+}
+
+// ParseJobsConfig parses SD jobs from YAML. Job names must be unique and only
+// contain letters, digits, underscores and dashes.
+func ParseJobsConfig(logger *slog.Logger, buf []byte) ([]*Job, error) {
+	var config JobsConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse SD jobs config: %w", err)
+	}
+
+	jobs := make([]*Job, 0, len(config.Jobs))
+	names := make(map[string]struct{}, len(config.Jobs))
+	for i, jc := range config.Jobs {
+		if jc == nil {
+			return nil, fmt.Errorf("empty SD job at index %d", i)
+		}
+		if !jobNameRegexp.MatchString(jc.Name) {
+			return nil, fmt.Errorf("invalid name %q of SD job at index %d", jc.Name, i)
+		}
+		if _, ok := names[jc.Name]; ok {
+			return nil, fmt.Errorf("duplicate SD job name %q", jc.Name)
+		}
+		names[jc.Name] = struct{}{}
+
+		job, err := NewJob(logger, jc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SD job %q: %w", jc.Name, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Job serves the targets of the worker's cached records that match its
+// filters. Jobs don't query the NS1 API themselves, their targets are rebuilt
+// from the worker's record cache after the worker refreshes it.
+type Job struct {
+	Name            string
+	TargetOptions   TargetOptions
+	RefreshInterval time.Duration
+
+	// targetCache, lastRefresh and generation are only accessed by the
+	// worker's refreshing goroutine. ServeHTTP reads the targetSet
+	// published by each refresh instead.
+	logger      *slog.Logger
+	query       *recordQuery
+	targetCache []*HTTPSDTarget
+	lastRefresh time.Time
+	generation  int
+	published   atomic.Pointer[targetSet]
+	response    responseCache
+}
+
+// NewJob creates a job from its config.
+func NewJob(logger *slog.Logger, config *JobConfig) (*Job, error) {
+	query := &recordQuery{
+		zones: config.Zones,
+		types: config.Types,
+		meta:  config.Meta,
+	}
+	if config.DomainRegex != "" {
+		re, err := regexp.Compile(config.DomainRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain regex: %w", err)
+		}
+		query.domain = re
+	}
+
+	job := Job{
+		Name:            config.Name,
+		RefreshInterval: config.RefreshInterval,
+		logger:          logger.With("sd_job", config.Name),
+		query:           query,
+	}
+
+	job.TargetOptions.StructuredLabels = config.StructuredLabels
+	job.TargetOptions.ExpandAliases = config.ExpandAliases
+	if config.TargetMode != "" {
+		mode, err := ParseTargetMode(config.TargetMode)
+		if err != nil {
+			return nil, err
+		}
+		job.TargetOptions.Mode = mode
+	}
+
+	if config.LinkMode != "" {
+		mode, err := ParseLinkMode(config.LinkMode)
+		if err != nil {
+			return nil, err
+		}
+		job.TargetOptions.LinkMode = mode
+	}
+
+	tmpl, err := ParseAddressTemplate(config.AddressTemplate)
+	if err != nil {
+		return nil, err
+	}
+	job.TargetOptions.AddressTemplate = tmpl
+
+	return &job, nil
+}
+
+// refresh rebuilds the job's targets from the worker's records, resolving
+// links and aliases against all of the worker's records and not only the
+// job's, unless the records haven't changed since the last refresh, identified
+// by their generation, or the job's refresh interval hasn't passed yet.
+// Skipped changes are picked up by a later refresh. It returns whether the
+// targets were rebuilt.
+func (j *Job) refresh(zones map[string]*ns1_internal.Zone, index recordIndex, records []*dns.Record, generation int, now time.Time) bool {
+	if generation == j.generation {
+		return false
+	}
+	if j.RefreshInterval > 0 && !j.lastRefresh.IsZero() && now.Sub(j.lastRefresh) < j.RefreshInterval {
+		return false
+	}
+
+	var matched []*dns.Record
+	for _, record := range records {
+		if j.query.match(record) {
+			matched = append(matched, record)
+		}
+	}
+
+	j.targetCache = buildTargets(j.logger, j.TargetOptions, zones, index, matched)
+	j.generation = generation
+	j.lastRefresh = now
+	j.published.Store(&targetSet{
+		endpoint:     j.endpoint(),
+		generation:   generation,
+		lastModified: now,
+		opts:         j.TargetOptions,
+		zones:        zones,
+		index:        index,
+		records:      matched,
+		targets:      j.targetCache,
+	})
+	j.logger.Debug("SD job Prometheus target group updated", "num_targets", len(j.targetCache))
+
+	return true
+}
+
+// ServeHTTP implements the http.Handler interface, serving the job's cached
+// targets. Query parameters further filter the job's records, the same as for
+// the worker.
+func (j *Job) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	set := j.published.Load()
+	if set == nil {
+		set = &targetSet{endpoint: j.endpoint(), opts: j.TargetOptions}
+	}
+
+	serveTargets(j.logger, writer, req, &j.response, set)
+}
+
+func (j *Job) endpoint() string {
+	return sdEndpoint + "/" + j.Name
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJobsConfig(t *testing.T) {
+	tests := map[string]struct {
+		config    string
+		wantNames []string
+		wantErr   bool
+	}{
+		"empty": {
+			config:    "",
+			wantNames: []string{},
+		},
+		"valid": {
+			config: `
+jobs:
+  - name: team-a
+    zones: [foo.bar]
+    types: [A, AAAA]
+    domain_regex: ^test
+    meta:
+      up: "1"
+    target_mode: answer
+    address_template: "{{.Rdata0}}:9100"
+    structured_labels: true
+    link_mode: suppress
+    expand_aliases: true
+    refresh_interval: 5m
+  - name: team_b
+`,
+			wantNames: []string{"team-a", "team_b"},
+		},
+		"unknown_field": {
+			config: `
+jobs:
+  - name: team-a
+    zone: foo.bar
+`,
+			wantErr: true,
+		},
+		"missing_name": {
+			config: `
+jobs:
+  - zones: [foo.bar]
+`,
+			wantErr: true,
+		},
+		"invalid_name": {
+			config: `
+jobs:
+  - name: team/a
+`,
+			wantErr: true,
+		},
+		"duplicate_name": {
+			config: `
+jobs:
+  - name: team-a
+  - name: team-a
+`,
+			wantErr: true,
+		},
+		"invalid_regex": {
+			config: `
+jobs:
+  - name: team-a
+    domain_regex: (
+`,
+			wantErr: true,
+		},
+		"invalid_target_mode": {
+			config: `
+jobs:
+  - name: team-a
+    target_mode: zone
+`,
+			wantErr: true,
+		},
+		"invalid_link_mode": {
+			config: `
+jobs:
+  - name: team-a
+    link_mode: follow
+`,
+			wantErr: true,
+		},
+		"invalid_template": {
+			config: `
+jobs:
+  - name: team-a
+    address_template: "{{.Rdata0"
+`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			jobs, err := ParseJobsConfig(mockLogger, []byte(tc.config))
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			names := []string{}
+			for _, job := range jobs {
+				names = append(names, job.Name)
+			}
+			require.Equal(t, tc.wantNames, names)
+		})
+	}
+}
+
+func TestJobRefresh(t *testing.T) {
+	jobs, err := ParseJobsConfig(mockLogger, []byte(`
+jobs:
+  - name: aaaa
+    types: [AAAA]
+  - name: slow
+    refresh_interval: 1h
+`))
+	require.NoError(t, err)
+
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.Jobs = jobs
+	worker.recordCache = mockDnsRecordCache[:1]
+	worker.RefreshPrometheusTargetData()
+
+	aaaa, slow := jobs[0], jobs[1]
+	require.Empty(t, aaaa.targetCache)
+	require.Equal(t, mockSDTargetCache[:1], slow.targetCache)
+
+	// both jobs see the new records, except for the job whose refresh
+	// interval hasn't passed yet
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+	require.Equal(t, mockSDTargetCache[1:], aaaa.targetCache)
+	require.Equal(t, mockSDTargetCache[:1], slow.targetCache)
+
+	// once the refresh interval passes, the skipped update is picked up
+	// without another change to the record cache
+	worker.refreshJobs(time.Now().Add(2 * time.Hour))
+	require.Equal(t, mockSDTargetCache, slow.targetCache)
+
+	// serving a job supports further filtering by query parameters
+	req := httptest.NewRequest(http.MethodGet, "/sd/slow?type=A", nil)
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []*HTTPSDTarget
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, mockSDTargetCache[:1], got)
+}
+
+func TestJobRefreshLinks(t *testing.T) {
+	jobs, err := ParseJobsConfig(mockLogger, []byte(`
+jobs:
+  - name: keep-me
+    zones: [keep.me]
+    target_mode: answer
+    link_mode: resolve
+`))
+	require.NoError(t, err)
+
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.Jobs = jobs
+	worker.recordCache = mockLinkedRecords
+	worker.RefreshPrometheusTargetData()
+
+	// links are resolved against all of the worker's records, not only
+	// the records of the job
+	require.Len(t, jobs[0].targetCache, 1)
+	require.Equal(t, []string{"1.2.3.4"}, jobs[0].targetCache[0].Targets)
+	require.Equal(t, "app.keep.me", string(jobs[0].targetCache[0].Labels[ns1RecordLabelDomain]))
+}
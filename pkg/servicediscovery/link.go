@@ -0,0 +1,128 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	promModel "github.com/prometheus/common/model"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+const (
+	ns1RecordLabelLinkTarget     = ns1Label + "record_link_target"
+	ns1RecordLabelLinkTargetZone = ns1Label + "record_link_target_zone"
+)
+
+var (
+	errLinkNotFound = errors.New("linked record not found in record cache")
+	errLinkLoop     = errors.New("link loop detected")
+)
+
+// LinkMode determines how linked records, which serve the answers of the
+// record they link to instead of their own, are converted into targets.
+type LinkMode string
+
+const (
+	// LinkModeInclude creates targets for linked records as they are,
+	// without answers. This is the default.
+	LinkModeInclude LinkMode = "include"
+	// LinkModeResolve follows the link chain of a linked record to the
+	// record at its end, and creates targets for that record's answers.
+	LinkModeResolve LinkMode = "resolve"
+	// LinkModeSuppress creates no targets for linked records.
+	LinkModeSuppress LinkMode = "suppress"
+)
+
+// ParseLinkMode parses a link mode, returning an error for unknown modes.
+func ParseLinkMode(mode string) (LinkMode, error) {
+	switch m := LinkMode(mode); m {
+	case LinkModeInclude, LinkModeResolve, LinkModeSuppress:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown link mode %q", mode)
+	}
+}
+
+// recordIndex indexes records by domain and type, which is how records
+// reference other records. If records are duplicated, the first one is kept.
+type recordIndex map[string]*dns.Record
+
+func newRecordIndex(records []*dns.Record) recordIndex {
+	index := make(recordIndex, len(records))
+	for _, record := range records {
+		key := recordIndexKey(record.Domain, record.Type)
+		if _, ok := index[key]; !ok {
+			index[key] = record
+		}
+	}
+
+	return index
+}
+
+func recordIndexKey(domain, recordType string) string {
+	return normalizeDomain(domain) + "/" + strings.ToUpper(recordType)
+}
+
+// normalizeDomain returns a domain in lower case without a trailing dot, so
+// that domains referenced in links and answers match the domains of records.
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// resolveLink follows the link chain of a record to the first record that
+// isn't linked, which is the record whose answers are served for the whole
+// chain. Links always point to a record of the same type. An error is
+// returned if a record in the chain isn't in the index, or if the chain loops.
+func (i recordIndex) resolveLink(record *dns.Record) (*dns.Record, error) {
+	seen := map[string]struct{}{
+		recordIndexKey(record.Domain, record.Type): {},
+	}
+
+	resolved := record
+	for resolved.Link != "" {
+		key := recordIndexKey(resolved.Link, record.Type)
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%w: %s links back to %s", errLinkLoop, resolved.Domain, resolved.Link)
+		}
+		seen[key] = struct{}{}
+
+		next, ok := i[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %s", errLinkNotFound, resolved.Link, record.Type)
+		}
+		resolved = next
+	}
+
+	return resolved, nil
+}
+
+// withLinkedAnswers returns a copy of a linked record with the answers,
+// filters, regions and meta of the record at the end of its link chain, and
+// the labels identifying that record.
+func withLinkedAnswers(record, target *dns.Record) (*dns.Record, promModel.LabelSet) {
+	linked := *record
+	linked.Answers = target.Answers
+	linked.Filters = target.Filters
+	linked.Regions = target.Regions
+	linked.Meta = target.Meta
+
+	return &linked, promModel.LabelSet{
+		ns1RecordLabelLinkTarget:     promModel.LabelValue(target.Domain),
+		ns1RecordLabelLinkTargetZone: promModel.LabelValue(target.Zone),
+	}
+}
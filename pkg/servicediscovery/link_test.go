@@ -0,0 +1,166 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+var mockLinkedRecords = []*dns.Record{
+	{Zone: "foo.bar", Domain: "www.foo.bar", Type: "A", Answers: []*dns.Answer{{ID: "a0", Rdata: []string{"1.2.3.4"}}}},
+	{Zone: "foo.bar", Domain: "web.foo.bar", Type: "A", Link: "www.foo.bar"},
+	{Zone: "keep.me", Domain: "app.keep.me", Type: "A", Link: "web.foo.bar."},
+	{Zone: "foo.bar", Domain: "missing.foo.bar", Type: "A", Link: "nope.foo.bar"},
+	{Zone: "foo.bar", Domain: "loop1.foo.bar", Type: "A", Link: "loop2.foo.bar"},
+	{Zone: "foo.bar", Domain: "loop2.foo.bar", Type: "A", Link: "loop1.foo.bar"},
+	{Zone: "foo.bar", Domain: "self.foo.bar", Type: "A", Link: "self.foo.bar"},
+	// links point to a record of the same type
+	{Zone: "foo.bar", Domain: "v6.foo.bar", Type: "AAAA", Link: "www.foo.bar"},
+}
+
+func TestParseLinkMode(t *testing.T) {
+	tests := map[string]struct {
+		mode    string
+		want    LinkMode
+		wantErr bool
+	}{
+		"resolve":  {mode: "resolve", want: LinkModeResolve},
+		"include":  {mode: "include", want: LinkModeInclude},
+		"suppress": {mode: "suppress", want: LinkModeSuppress},
+		"unknown":  {mode: "follow", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseLinkMode(tc.mode)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	index := newRecordIndex(mockLinkedRecords)
+
+	tests := map[string]struct {
+		record  *dns.Record
+		want    *dns.Record
+		wantErr error
+	}{
+		"unlinked":          {record: mockLinkedRecords[0], want: mockLinkedRecords[0]},
+		"linked":            {record: mockLinkedRecords[1], want: mockLinkedRecords[0]},
+		"chain_across_zone": {record: mockLinkedRecords[2], want: mockLinkedRecords[0]},
+		"missing":           {record: mockLinkedRecords[3], wantErr: errLinkNotFound},
+		"loop":              {record: mockLinkedRecords[4], wantErr: errLinkLoop},
+		"self_loop":         {record: mockLinkedRecords[6], wantErr: errLinkLoop},
+		"other_type":        {record: mockLinkedRecords[7], wantErr: errLinkNotFound},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := index.resolveLink(tc.record)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Same(t, tc.want, got)
+		})
+	}
+}
+
+func TestRecordTargetsLinkMode(t *testing.T) {
+	index := newRecordIndex(mockLinkedRecords)
+	record := mockLinkedRecords[2]
+
+	tests := map[string]struct {
+		mode    LinkMode
+		record  *dns.Record
+		want    []string
+		labeled bool
+	}{
+		"resolve": {
+			mode:    LinkModeResolve,
+			record:  record,
+			want:    []string{"1.2.3.4"},
+			labeled: true,
+		},
+		"default_include": {
+			record: record,
+		},
+		"include": {
+			mode:   LinkModeInclude,
+			record: record,
+		},
+		"suppress": {
+			mode:   LinkModeSuppress,
+			record: record,
+		},
+		// unresolvable links are converted as they are
+		"resolve_loop": {
+			mode:   LinkModeResolve,
+			record: mockLinkedRecords[4],
+		},
+		"suppress_unlinked": {
+			mode:   LinkModeSuppress,
+			record: mockLinkedRecords[0],
+			want:   []string{"1.2.3.4"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := TargetOptions{Mode: TargetModeAnswer, LinkMode: tc.mode}
+			targets := recordTargets(mockLogger, opts, nil, index, tc.record)
+
+			var got []string
+			for _, target := range targets {
+				got = append(got, target.Targets...)
+
+				require.Equal(t, tc.record.Domain, string(target.Labels[ns1RecordLabelDomain]))
+				require.Equal(t, tc.record.Link, string(target.Labels[ns1RecordLabelLink]))
+				if tc.labeled {
+					require.Equal(t, "www.foo.bar", string(target.Labels[ns1RecordLabelLinkTarget]))
+					require.Equal(t, "foo.bar", string(target.Labels[ns1RecordLabelLinkTargetZone]))
+				} else {
+					require.NotContains(t, target.Labels, ns1RecordLabelLinkTarget)
+				}
+			}
+			require.Equal(t, tc.want, got)
+
+			// record mode creates a target for linked records, unless
+			// they are suppressed
+			opts.Mode = TargetModeRecord
+			targets = recordTargets(mockLogger, opts, nil, index, tc.record)
+			if tc.mode == LinkModeSuppress && tc.record.Link != "" {
+				require.Empty(t, targets)
+			} else {
+				require.Len(t, targets, 1)
+			}
+		})
+	}
+
+	// the cached record isn't modified by resolving its link
+	require.Empty(t, record.Answers)
+}
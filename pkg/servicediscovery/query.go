@@ -0,0 +1,119 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+const (
+	queryParamZone        = "zone"
+	queryParamType        = "type"
+	queryParamDomainRegex = "domain_regex"
+	queryParamMetaPrefix  = "meta."
+)
+
+// recordQuery selects the cached records that targets are returned for, from
+// the query parameters of a request to the `/sd` endpoint:
+//
+//   - zone: name of the record's zone. May be repeated to match any of them.
+//   - type: type of the record, case-insensitive. May be repeated to match
+//     any of them.
+//   - domain_regex: unanchored regular expression that the record's domain
+//     must match.
+//   - meta.<key>: value of the record's meta key. May be given for multiple
+//     keys, in which case all of them must match.
+type recordQuery struct {
+	zones  []string
+	types  []string
+	domain *regexp.Regexp
+	meta   map[string]string
+}
+
+// parseRecordQuery parses the query parameters of a request to the `/sd`
+// endpoint. Unknown parameters and invalid regular expressions return an
+// error.
+func parseRecordQuery(values url.Values) (*recordQuery, error) {
+	query := recordQuery{}
+
+	for key, vals := range values {
+		switch {
+		case key == queryParamZone:
+			query.zones = vals
+		case key == queryParamType:
+			query.types = vals
+		case key == queryParamDomainRegex:
+			if len(vals) != 1 {
+				return nil, fmt.Errorf("query parameter %q must be given once", key)
+			}
+
+			re, err := regexp.Compile(vals[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression in query parameter %q: %w", key, err)
+			}
+			query.domain = re
+		case strings.HasPrefix(key, queryParamMetaPrefix):
+			metaKey := strings.TrimPrefix(key, queryParamMetaPrefix)
+			if metaKey == "" {
+				return nil, fmt.Errorf("query parameter %q is missing a meta key", key)
+			}
+			if len(vals) != 1 {
+				return nil, fmt.Errorf("query parameter %q must be given once", key)
+			}
+
+			if query.meta == nil {
+				query.meta = make(map[string]string)
+			}
+			query.meta[metaKey] = vals[0]
+		default:
+			return nil, fmt.Errorf("unknown query parameter %q", key)
+		}
+	}
+
+	return &query, nil
+}
+
+// match returns whether a record matches all of the query's conditions.
+func (q *recordQuery) match(record *dns.Record) bool {
+	if len(q.zones) > 0 && !slices.Contains(q.zones, record.Zone) {
+		return false
+	}
+
+	if len(q.types) > 0 && !slices.ContainsFunc(q.types, func(t string) bool { return strings.EqualFold(t, record.Type) }) {
+		return false
+	}
+
+	if q.domain != nil && !q.domain.MatchString(record.Domain) {
+		return false
+	}
+
+	if len(q.meta) > 0 {
+		meta := metaAsStringMap(record.Meta)
+		for key, want := range q.meta {
+			got, ok := meta[key]
+			if !ok || got != want {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+func TestRecordQueryMatch(t *testing.T) {
+	record := &dns.Record{Zone: "foo.bar", Domain: "test.foo.bar", Type: "A", Meta: &data.Meta{Up: true, Note: "primary"}}
+
+	tests := map[string]struct {
+		query string
+		want  bool
+	}{
+		"empty":              {query: "", want: true},
+		"zone":               {query: "zone=foo.bar", want: true},
+		"zone_no_match":      {query: "zone=keep.me", want: false},
+		"zone_any":           {query: "zone=keep.me&zone=foo.bar", want: true},
+		"type":               {query: "type=a", want: true},
+		"type_no_match":      {query: "type=AAAA", want: false},
+		"domain_regex":       {query: "domain_regex=^test%5C.", want: true},
+		"domain_no_match":    {query: "domain_regex=^www%5C.", want: false},
+		"meta":               {query: "meta.up=1&meta.note=primary", want: true},
+		"meta_no_match":      {query: "meta.up=1&meta.note=secondary", want: false},
+		"meta_missing_key":   {query: "meta.weight=1", want: false},
+		"all_conditions":     {query: "zone=foo.bar&type=A&domain_regex=test&meta.up=1", want: true},
+		"one_condition_fail": {query: "zone=foo.bar&type=A&domain_regex=www", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+
+			query, err := parseRecordQuery(values)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, query.match(record))
+		})
+	}
+}
+
+func TestParseRecordQueryErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid_regex":       "domain_regex=(",
+		"repeated_regex":      "domain_regex=a&domain_regex=b",
+		"missing_meta_key":    "meta.=1",
+		"repeated_meta_value": "meta.up=1&meta.up=0",
+		"unknown_param":       "zones=foo.bar",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			values, err := url.ParseQuery(query)
+			require.NoError(t, err)
+
+			_, err = parseRecordQuery(values)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestServeHTTPQuery(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	tests := map[string]struct {
+		query      string
+		wantStatus int
+		want       []*HTTPSDTarget
+	}{
+		"no_query":      {query: "", wantStatus: http.StatusOK, want: mockSDTargetCache},
+		"type":          {query: "?type=AAAA", wantStatus: http.StatusOK, want: mockSDTargetCache[1:]},
+		"meta":          {query: "?meta.up=1", wantStatus: http.StatusOK, want: mockSDTargetCache[:1]},
+		"no_match":      {query: "?zone=keep.me", wantStatus: http.StatusOK, want: []*HTTPSDTarget{}},
+		"bad_regex":     {query: "?domain_regex=(", wantStatus: http.StatusBadRequest},
+		"unknown_param": {query: "?foo=bar", wantStatus: http.StatusBadRequest},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sd"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			worker.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.wantStatus, rec.Code)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got []*HTTPSDTarget
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
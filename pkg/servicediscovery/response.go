@@ -0,0 +1,222 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+// sdResponse is a serialized list of targets, along with its gzip compressed
+// form and the values of its caching headers.
+type sdResponse struct {
+	body         []byte
+	gzipBody     []byte
+	etag         string
+	lastModified time.Time
+}
+
+func newSDResponse(targets []*HTTPSDTarget, lastModified time.Time) (*sdResponse, error) {
+	body, err := json.MarshalIndent(targets, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal targets: %w", err)
+	}
+
+	var gzipBody bytes.Buffer
+	zw := gzip.NewWriter(&gzipBody)
+	if _, err := zw.Write(body); err != nil {
+		return nil, fmt.Errorf("failed to compress targets: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress targets: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+
+	return &sdResponse{
+		body:         body,
+		gzipBody:     gzipBody.Bytes(),
+		etag:         hex.EncodeToString(sum[:16]),
+		lastModified: lastModified.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// serve writes the response, compressed if the client accepts gzip. The
+// compressed and uncompressed bodies have different strong ETags, and
+// conditional requests are handled by http.ServeContent.
+func (r *sdResponse) serve(writer http.ResponseWriter, req *http.Request) {
+	body, etag := r.body, `"`+r.etag+`"`
+
+	header := writer.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if acceptsGzip(req) {
+		body, etag = r.gzipBody, `"`+r.etag+`-gzip"`
+		header.Set("Content-Encoding", "gzip")
+	}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("ETag", etag)
+
+	http.ServeContent(writer, req, "", r.lastModified, bytes.NewReader(body))
+}
+
+// acceptsGzip returns whether the request's Accept-Encoding header allows a
+// gzip compressed response.
+func acceptsGzip(req *http.Request) bool {
+	for _, header := range req.Header.Values("Accept-Encoding") {
+		for coding := range strings.SplitSeq(header, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+				continue
+			}
+
+			// a quality value of 0 means "not acceptable"
+			for param := range strings.SplitSeq(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if q, err := strconv.ParseFloat(value, 64); strings.EqualFold(key, "q") && err == nil && q == 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// targetSet is the state needed to serve the targets of the worker or a job.
+type targetSet struct {
+	// endpoint identifies the handler in the SD request metrics.
+	endpoint string
+	// generation identifies the cached targets, which are rebuilt from
+	// the cached records at lastModified.
+	generation   int
+	lastModified time.Time
+	opts         TargetOptions
+	zones        map[string]*ns1_internal.Zone
+	index        recordIndex
+	records      []*dns.Record
+	targets      []*HTTPSDTarget
+}
+
+// serveTargets serves the cached targets of a target set, or, if the request
+// has query parameters, the targets of the set's records that match them. The
+// response for the cached targets is only built once per generation.
+func serveTargets(logger *slog.Logger, writer http.ResponseWriter, req *http.Request, cache *responseCache, set *targetSet) {
+	counter := &countingResponseWriter{ResponseWriter: writer}
+	defer func() {
+		metrics.MetricSDRequests.WithLabelValues(set.endpoint, strconv.Itoa(counter.code)).Inc()
+		metrics.MetricSDResponseBytes.WithLabelValues(set.endpoint).Add(float64(counter.bytes))
+	}()
+
+	var (
+		response *sdResponse
+		err      error
+	)
+	if values := req.URL.Query(); len(values) > 0 {
+		var query *recordQuery
+		query, err = parseRecordQuery(values)
+		if err != nil {
+			http.Error(counter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// filtered responses aren't cached, since the query
+		// parameters are unbounded
+		targets := []*HTTPSDTarget{}
+		for _, record := range set.records {
+			if query.match(record) {
+				targets = append(targets, recordTargets(logger, set.opts, set.zones, set.index, record)...)
+			}
+		}
+		response, err = newSDResponse(targets, set.lastModified)
+	} else {
+		response, err = cache.get(set)
+	}
+	if err != nil {
+		logger.Error("Failed to convert DNS records from NS1 API into Prometheus Targets", "err", err)
+		http.Error(counter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.serve(counter, req)
+}
+
+// responseCache caches the response for the current generation of a set of
+// targets, so that the targets are only serialized and compressed once per
+// generation, no matter how many requests are served.
+type responseCache struct {
+	mu         sync.Mutex
+	generation int
+	response   *sdResponse
+}
+
+func (c *responseCache) get(set *targetSet) (*sdResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.response != nil && c.generation == set.generation {
+		return c.response, nil
+	}
+
+	response, err := newSDResponse(set.targets, set.lastModified)
+	if err != nil {
+		return nil, err
+	}
+	c.response, c.generation = response, set.generation
+
+	return response, nil
+}
+
+// countingResponseWriter records the status code and number of body bytes of
+// a response, for the SD request metrics.
+type countingResponseWriter struct {
+	http.ResponseWriter
+
+	code  int
+	bytes int
+}
+
+func (w *countingResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		want   bool
+	}{
+		"none":        {header: "", want: false},
+		"gzip":        {header: "gzip", want: true},
+		"list":        {header: "deflate, GZIP;q=0.5, br", want: true},
+		"q_zero":      {header: "gzip;q=0", want: false},
+		"q_zero_frac": {header: "gzip; q=0.000", want: false},
+		"other":       {header: "br, deflate", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sd", nil)
+			if tc.header != "" {
+				req.Header.Set("Accept-Encoding", tc.header)
+			}
+			require.Equal(t, tc.want, acceptsGzip(req))
+		})
+	}
+}
+
+func TestServeHTTPCaching(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/sd", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		worker.ServeHTTP(rec, req)
+		return rec
+	}
+
+	requests := testutil.ToFloat64(metrics.MetricSDRequests.WithLabelValues(sdEndpoint, "200"))
+	notModified := testutil.ToFloat64(metrics.MetricSDRequests.WithLabelValues(sdEndpoint, "304"))
+	bytesServed := testutil.ToFloat64(metrics.MetricSDResponseBytes.WithLabelValues(sdEndpoint))
+
+	// the response is only built once per generation of targets
+	rec := serve(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, string(mockTargetJSON), rec.Body.String())
+	response := worker.response.response
+
+	etag := rec.Header().Get("ETag")
+	require.Equal(t, `"`+response.etag+`"`, etag)
+	require.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	lastModified, err := http.ParseTime(rec.Header().Get("Last-Modified"))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), lastModified, time.Minute)
+
+	rec = serve(http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Empty(t, rec.Body.Bytes())
+	require.Same(t, response, worker.response.response)
+
+	rec = serve(http.Header{"If-None-Match": {`"stale"`}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(http.Header{"If-Modified-Since": {lastModified.Add(time.Second).Format(http.TimeFormat)}})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	// compressed responses have their own ETag
+	rec = serve(http.Header{"Accept-Encoding": {"gzip"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gzipETag := rec.Header().Get("ETag")
+	require.Equal(t, `"`+response.etag+`-gzip"`, gzipETag)
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, string(mockTargetJSON), string(body))
+
+	rec = serve(http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {gzipETag}})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	require.InDelta(t, requests+3, testutil.ToFloat64(metrics.MetricSDRequests.WithLabelValues(sdEndpoint, "200")), 0)
+	require.InDelta(t, notModified+3, testutil.ToFloat64(metrics.MetricSDRequests.WithLabelValues(sdEndpoint, "304")), 0)
+	require.InDelta(t, bytesServed+float64(2*len(response.body)+len(response.gzipBody)), testutil.ToFloat64(metrics.MetricSDResponseBytes.WithLabelValues(sdEndpoint)), 0)
+
+	// new targets get a new response and ETag
+	worker.recordCache = mockDnsRecordCache[:1]
+	worker.RefreshPrometheusTargetData()
+	rec = serve(http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotSame(t, response, worker.response.response)
+	require.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestServeHTTPConcurrentRefresh(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	job, err := NewJob(mockLogger, &JobConfig{Name: "all"})
+	require.NoError(t, err)
+	worker.Jobs = []*Job{job}
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			worker.recordCache = mockDnsRecordCache[:i%len(mockDnsRecordCache)+1]
+			worker.RefreshPrometheusTargetData()
+		}
+	}()
+
+	// the ETag of each response matches its body, no matter which
+	// generation of targets it was built from
+	for _, handler := range []http.Handler{worker, job} {
+		for range 50 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			sum := sha256.Sum256(rec.Body.Bytes())
+			require.Equal(t, `"`+hex.EncodeToString(sum[:16])+`"`, rec.Header().Get("ETag"))
+		}
+	}
+	wg.Wait()
+}
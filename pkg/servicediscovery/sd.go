@@ -15,14 +15,17 @@
 package servicediscovery
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	promModel "github.com/prometheus/common/model"
@@ -34,28 +37,44 @@ import (
 
 	"github.com/tjhop/ns1_exporter/pkg/metrics"
 	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+	"github.com/tjhop/ns1_exporter/pkg/storage"
 )
 
 const (
 	ns1Label                             = promModel.MetaLabelPrefix + "ns1_"
+	ns1RecordLabelAnswer                 = ns1Label + "record_answer"
 	ns1RecordLabelAnswers                = ns1Label + "record_answers"
 	ns1RecordLabelDomain                 = ns1Label + "record_domain"
+	ns1RecordLabelFilter                 = ns1Label + "record_filter"
 	ns1RecordLabelFilters                = ns1Label + "record_filters"
 	ns1RecordLabelID                     = ns1Label + "record_id"
 	ns1RecordLabelLink                   = ns1Label + "record_link"
 	ns1RecordLabelMeta                   = ns1Label + "record_meta"
 	ns1RecordLabelOverrideAddressRecords = ns1Label + "record_override_address_records_enabled"
 	ns1RecordLabelOverrideTTL            = ns1Label + "record_override_ttl_enabled"
+	ns1RecordLabelRegion                 = ns1Label + "record_region"
 	ns1RecordLabelRegions                = ns1Label + "record_regions"
 	ns1RecordLabelTTL                    = ns1Label + "record_ttl"
 	ns1RecordLabelType                   = ns1Label + "record_type"
 	ns1RecordLabelUseClientSubnet        = ns1Label + "record_use_client_subnet_enabled"
 	ns1RecordLabelZone                   = ns1Label + "record_zone"
+
+	snapshotName = "http_sd"
+	sdEndpoint   = "/sd"
 )
 
+// snapshot is the subset of the worker's state that is persisted to disk when
+// storage is enabled. Prometheus targets are not stored, since they are
+// rebuilt from the record cache.
+type snapshot struct {
+	Zones       map[string]*ns1_internal.Zone `json:"zones"`
+	Records     []*dns.Record                 `json:"records"`
+	LastRefresh time.Time                     `json:"last_refresh"`
+}
+
 type HTTPSDTarget struct {
-	Targets []string           `json:"targets"`
-	Labels  promModel.LabelSet `json:"labels"`
+	Targets []string           `json:"targets" yaml:"targets"`
+	Labels  promModel.LabelSet `json:"labels" yaml:"labels"`
 }
 
 // Worker contains an API client to interact with the NS1 api, as well as a
@@ -66,17 +85,49 @@ type Worker struct {
 	ZoneBlacklist       *regexp.Regexp
 	ZoneWhitelist       *regexp.Regexp
 	RecordTypeWhitelist *regexp.Regexp
-
-	logger               *slog.Logger
-	client               *api.Client
-	zoneCache            map[string]*ns1_internal.Zone
-	recordCache          []*dns.Record
+	// Views configures the lookup of each zone's NS1 DNS views, and
+	// filtering of zones by view and network.
+	Views         ns1_internal.ViewOptions
+	TargetOptions TargetOptions
+	// Jobs are optional. Their targets are rebuilt from the worker's
+	// record cache, so that the NS1 API is only queried once for all of
+	// them.
+	Jobs []*Job
+	// FileSD is optional. When set, the targets of the worker and each of
+	// its jobs are written to file_sd files whenever they change.
+	FileSD *FileSDWriter
+	// Storage is optional. When set, the worker persists its caches after
+	// each data refresh and can restore them on startup via LoadSnapshot().
+	Storage *storage.Store
+
+	// The caches and generation are only accessed by the goroutine
+	// refreshing them. HTTP handlers and collectors read the targetSet
+	// published for each generation instead.
+	logger      *slog.Logger
+	client      ns1_internal.Backend
+	zoneCache   map[string]*ns1_internal.Zone
+	recordCache []*dns.Record
+	// index indexes the record cache, to resolve the link chains of
+	// linked records and the CNAME/ALIAS chains of alias records.
+	index                recordIndex
 	targetCache          []*HTTPSDTarget
 	lastRefreshTimestamp time.Time
 	pollCount            int
+	// generation is incremented each time the targets are rebuilt from
+	// the record cache, at targetsRefreshed, so that jobs and the response
+	// cache can tell whether it changed.
+	generation       int
+	targetsRefreshed time.Time
+	response         responseCache
+	// published is the immutable targetSet of the current generation.
+	published atomic.Pointer[targetSet]
+	// watchMu guards publishing a new generation, and the channel closed
+	// when the generation changes, for blocking queries.
+	watchMu      sync.Mutex
+	watchChanged chan struct{}
 }
 
-func NewWorker(logger *slog.Logger, client *api.Client, blacklist, whitelist, recordType *regexp.Regexp) *Worker {
+func NewWorker(logger *slog.Logger, client ns1_internal.Backend, blacklist, whitelist, recordType *regexp.Regexp) *Worker {
 	worker := Worker{
 		client:              client,
 		ZoneBlacklist:       blacklist,
@@ -224,26 +275,119 @@ func recordAsPrometheusTarget(record *dns.Record) *HTTPSDTarget {
 	return &target
 }
 
+// RefreshPrometheusTargetData rebuilds the worker's targets from its record
+// cache, and the targets of its jobs that are due for a refresh.
 func (w *Worker) RefreshPrometheusTargetData() {
-	var data []*HTTPSDTarget
+	w.index = newRecordIndex(w.recordCache)
+	w.targetCache = buildTargets(w.logger, w.TargetOptions, w.zoneCache, w.index, w.recordCache)
+	w.logger.Debug("Worker Prometheus target group updated", "num_targets", len(w.targetCache))
+
+	w.targetsRefreshed = time.Now()
+	w.publish()
+	w.writeFileSD(fileSDName, w.targetCache)
+	w.refreshJobs(w.targetsRefreshed)
+}
 
-	for _, record := range w.recordCache {
-		data = append(data, recordAsPrometheusTarget(record))
+// publish increments the generation of the worker's targets, and publishes the
+// caches they were built from for HTTP handlers and collectors. The published
+// caches must not be modified afterwards, refreshes replace them instead.
+func (w *Worker) publish() {
+	w.watchMu.Lock()
+	defer w.watchMu.Unlock()
+
+	w.generation++
+	w.published.Store(&targetSet{
+		endpoint:     sdEndpoint,
+		generation:   w.generation,
+		lastModified: w.targetsRefreshed,
+		opts:         w.TargetOptions,
+		zones:        w.zoneCache,
+		index:        w.index,
+		records:      w.recordCache,
+		targets:      w.targetCache,
+	})
+
+	if w.watchChanged != nil {
+		close(w.watchChanged)
+		w.watchChanged = nil
 	}
+}
 
-	w.targetCache = data
-	w.logger.Debug("Worker Prometheus target group updated", "num_targets", len(w.targetCache))
+// current returns the published targetSet of the current generation. Before
+// the first generation is published, it returns an empty set.
+func (w *Worker) current() *targetSet {
+	if set := w.published.Load(); set != nil {
+		return set
+	}
+
+	return &targetSet{endpoint: sdEndpoint, opts: w.TargetOptions}
 }
 
-func (w *Worker) RefreshZoneData() {
-	w.zoneCache = ns1_internal.RefreshZoneData(w.logger, w.client, true, w.ZoneBlacklist, w.ZoneWhitelist)
+// watch returns the current generation of targets, and a channel that is
+// closed when it changes.
+func (w *Worker) watch() (int, <-chan struct{}) {
+	w.watchMu.Lock()
+	defer w.watchMu.Unlock()
+
+	if w.watchChanged == nil {
+		w.watchChanged = make(chan struct{})
+	}
+
+	return w.current().generation, w.watchChanged
+}
+
+// refreshJobs rebuilds the targets of the worker's jobs from its record cache,
+// for the jobs that are due for a refresh.
+func (w *Worker) refreshJobs(now time.Time) {
+	for _, job := range w.Jobs {
+		if job.refresh(w.zoneCache, w.index, w.recordCache, w.generation, now) {
+			w.writeFileSD(fileSDName+"_"+job.Name, job.targetCache)
+		}
+	}
+}
+
+// writeFileSD writes targets to the file_sd file with the provided name, if
+// file_sd is enabled.
+func (w *Worker) writeFileSD(name string, targets []*HTTPSDTarget) {
+	if w.FileSD == nil {
+		return
+	}
+
+	if err := w.FileSD.Write(name, targets); err != nil {
+		w.logger.Error("Failed to write targets to file_sd file", "err", err, "name", name)
+	}
+}
+
+// RefreshZoneData updates the worker's zone cache from the NS1 API. If the
+// zones can't be listed, the previous zone cache is kept and an error is
+// returned.
+func (w *Worker) RefreshZoneData() error {
+	zones, err := ns1_internal.RefreshZoneData(w.logger, w.client, true, w.ZoneBlacklist, w.ZoneWhitelist, w.Views, w.zoneCache)
+	if err != nil {
+		return err
+	}
+	w.zoneCache = zones
+
+	return nil
 }
 
 func (w *Worker) RefreshRecordData() {
 	var records []*dns.Record
 
+	// index the previous record data, so that a record that can't be
+	// retrieved keeps its previous data instead of disappearing until the
+	// next successful refresh
+	previous := make(map[string]*dns.Record, len(w.recordCache))
+	for _, r := range w.recordCache {
+		previous[recordKey(r.Zone, r.Domain, r.Type)] = r
+	}
+
 	for zName, zData := range w.zoneCache {
-		// if record type regex is provided, filter records
+		zoneRecords := zData.Records
+
+		// if record type regex is provided, filter records. the zone
+		// cache is left untouched, so that it always holds every
+		// record in the zone.
 		if w.RecordTypeWhitelist != nil && w.RecordTypeWhitelist.String() != "" {
 			var filteredRecords []*ns1_internal.ZoneRecord
 			for _, r := range zData.Records {
@@ -255,15 +399,20 @@ func (w *Worker) RefreshRecordData() {
 				filteredRecords = append(filteredRecords, r)
 			}
 
-			zData.Records = filteredRecords
+			zoneRecords = filteredRecords
 		}
 
-		for _, r := range zData.Records {
+		for _, r := range zoneRecords {
 			w.logger.Debug("Refreshing record data from NS1 API", "zone_name", zName, "record_domain", r.Domain, "record_type", r.Type)
-			record, _, err := w.client.Records.Get(zData.Zone, r.Domain, r.Type)
+			record, err := w.client.GetRecord(zData.Zone, r.Domain, r.Type)
 			if err != nil {
-				w.logger.Error("Failed to get record data from NS1 API", "err", err, "zone_name", zName, "record_domain", r.Domain, "record_type", r.Type)
 				metrics.MetricExporterNS1APIFailures.Inc()
+				if prev, ok := previous[recordKey(zData.Zone, r.Domain, r.Type)]; ok {
+					w.logger.Error("Failed to get record data from NS1 API, keeping previous record data", "err", err, "zone_name", zName, "record_domain", r.Domain, "record_type", r.Type)
+					records = append(records, prev)
+					continue
+				}
+				w.logger.Error("Failed to get record data from NS1 API", "err", err, "zone_name", zName, "record_domain", r.Domain, "record_type", r.Type)
 				continue
 			}
 			records = append(records, record)
@@ -274,12 +423,23 @@ func (w *Worker) RefreshRecordData() {
 	w.logger.Debug("Worker record cache updated", "num_records", len(w.recordCache))
 }
 
-func (w *Worker) RefreshData() {
+func recordKey(zone, domain, recordType string) string {
+	return zone + "/" + domain + "/" + recordType
+}
+
+// RefreshData refreshes the worker's zone, record and target caches. If the
+// zones can't be listed from the NS1 API, all caches are left untouched and
+// an error is returned.
+func (w *Worker) RefreshData() error {
 	w.logger.Info("Updating record data from NS1 API")
-	w.RefreshZoneData()
+	if err := w.RefreshZoneData(); err != nil {
+		return err
+	}
 	w.RefreshRecordData()
 	w.logger.Info("Updating prometheus target data from cached record data")
 	w.RefreshPrometheusTargetData()
+
+	return nil
 }
 
 func (w *Worker) Refresh() {
@@ -293,10 +453,14 @@ func (w *Worker) Refresh() {
 			{Key: "limit", Value: "1000"},
 		}
 		w.logger.Debug("Refreshing account activity from NS1 API")
-		activity, _, err := w.client.Activity.List(params...)
+		activity, err := w.client.ListActivity(params...)
 		if err != nil {
+			// keep the previous data and poll from the same start
+			// time again on the next refresh, so that no activity
+			// is missed
 			w.logger.Error("Failed to get account activity from NS1 API", "err", err)
 			metrics.MetricExporterNS1APIFailures.Inc()
+			return
 		}
 		w.pollCount++
 
@@ -309,10 +473,8 @@ func (w *Worker) Refresh() {
 			// activity detected, filter to only care about activity that can affect zones/records, that's what we care about
 			var filteredActivity []*account.Activity
 			for _, a := range activity {
-				switch a.ResourceType {
-				case "dns_zone", "record", "notify_list", "datasource", "datafeed", "job":
+				if ns1_internal.IsZoneActivity(a) {
 					filteredActivity = append(filteredActivity, a)
-				default:
 				}
 			}
 
@@ -323,28 +485,76 @@ func (w *Worker) Refresh() {
 	}
 
 	if needsRefresh {
-		w.RefreshData()
+		if err := w.RefreshData(); err != nil {
+			w.logger.Error("Failed to refresh data, keeping previous targets", "err", err)
+			return
+		}
 		w.pollCount = 0
+		w.SaveSnapshot(ts)
 	}
 
+	// jobs that skipped a record cache update because of their refresh
+	// interval pick it up here once they're due
+	w.refreshJobs(time.Now())
 	w.lastRefreshTimestamp = ts
 }
 
-func (w *Worker) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
-	buf, err := json.MarshalIndent(w.targetCache, "", "    ")
+// LoadSnapshot restores the worker's zone and record caches from the most
+// recent on-disk snapshot, if storage is enabled and a usable snapshot exists,
+// and rebuilds the Prometheus targets from them. The timestamp of the
+// snapshot's data refresh is restored as well, so that the first call to
+// Refresh() only polls account activity since the snapshot was taken instead
+// of unconditionally refreshing every record.
+func (w *Worker) LoadSnapshot() {
+	if w.Storage == nil {
+		return
+	}
+
+	var snap snapshot
+	ts, err := w.Storage.Load(snapshotName, &snap)
 	if err != nil {
-		w.logger.Error("Failed to convert DNS records from NS1 API into Prometheus Targets", "err", err)
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, os.ErrNotExist) {
+			w.logger.Info("No snapshot found on disk, starting with empty caches")
+			return
+		}
+		w.logger.Warn("Failed to load snapshot from disk, starting with empty caches", "err", err)
+		return
+	}
+
+	w.zoneCache = snap.Zones
+	w.recordCache = snap.Records
+	w.lastRefreshTimestamp = snap.LastRefresh
+	w.RefreshPrometheusTargetData()
+	w.logger.Info("Loaded snapshot from disk", "snapshot_time", ts, "num_zones", len(w.zoneCache), "num_records", len(w.recordCache))
+}
+
+// SaveSnapshot persists the worker's zone and record caches to disk, if
+// storage is enabled, along with the time at which the data refresh that
+// produced them was started. Empty caches are not saved, to avoid replacing
+// the last good snapshot with the result of a failed refresh.
+func (w *Worker) SaveSnapshot(refreshedAt time.Time) {
+	if w.Storage == nil {
+		return
+	}
+
+	if len(w.recordCache) == 0 {
+		w.logger.Debug("Worker record cache is empty, skipping snapshot")
 		return
 	}
 
-	writer.Header().Set("content-type", "application/json; charset=utf-8")
-	writer.WriteHeader(http.StatusOK)
-	if bytesWritten, err := writer.Write(buf); err != nil {
-		w.logger.Error("Failed to write full HTTP response", "err", err, "bytes", bytesWritten)
+	if err := w.Storage.Save(snapshotName, snapshot{Zones: w.zoneCache, Records: w.recordCache, LastRefresh: refreshedAt}); err != nil {
+		w.logger.Error("Failed to save snapshot to disk", "err", err)
 	}
 }
 
+// ServeHTTP implements the http.Handler interface, serving the worker's
+// cached targets. If the request has query parameters, only targets for the
+// cached records that match them are served, and invalid query parameters are
+// rejected with a 400 response. See recordQuery for the supported parameters.
+func (w *Worker) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	serveTargets(w.logger, writer, req, &w.response, w.current())
+}
+
 func getMapKeys(m map[string]any) []string {
 	keys := make([]string, len(m))
 
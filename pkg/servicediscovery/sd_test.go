@@ -34,7 +34,9 @@ import (
 	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
 	"gopkg.in/ns1/ns1-go.v2/rest/model/filter"
 
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
 	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+	"github.com/tjhop/ns1_exporter/pkg/storage"
 )
 
 var (
@@ -221,7 +223,7 @@ func TestRecordAsPrometheusTarget(t *testing.T) {
 	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
 	require.NoError(t, err)
 
-	worker := NewWorker(mockLogger, mockClient, nil, nil, nil)
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
 
 	tests := map[string]struct {
 		recordCache []*dns.Record
@@ -268,7 +270,7 @@ func TestRefreshRecordData(t *testing.T) {
 	}
 
 	for name, tc := range tests {
-		worker := NewWorker(mockLogger, mockClient, nil, nil, tc.recordTypeWhitelist)
+		worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, tc.recordTypeWhitelist)
 		worker.zoneCache = tc.zoneCache
 
 		t.Run(name, func(t *testing.T) {
@@ -288,6 +290,91 @@ func TestRefreshRecordData(t *testing.T) {
 	}
 }
 
+func TestRefreshRecordDataKeepsPrevious(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	// only the first record can be retrieved, the others fail
+	record := mockDnsRecordCache[0]
+	require.NoError(t, mock.AddTestCase(http.MethodGet, fmt.Sprintf("zones/%s/%s/%s", record.Zone, record.Domain, record.Type),
+		http.StatusOK, nil, nil, "", record),
+	)
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
+	worker.zoneCache = mockZoneCache
+	worker.recordCache = mockDnsRecordCache[1:]
+
+	worker.RefreshRecordData()
+	require.ElementsMatch(t, mockDnsRecordCache, worker.recordCache)
+}
+
+func TestRefreshDataMergedZones(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	// the same zone on two networks is one zone to the NS1 API, which
+	// looks zones and records up by name
+	record := mockDnsRecordCache[0]
+	require.NoError(t, mock.AddTestCase(http.MethodGet, "zones", http.StatusOK, nil, nil, "", []*dns.Zone{
+		{Zone: "foo.bar", NetworkIDs: []int{0}},
+		{Zone: "foo.bar", NetworkIDs: []int{5}},
+	}))
+	require.NoError(t, mock.AddTestCase(http.MethodGet, "zones/foo.bar", http.StatusOK, nil, nil, "", &dns.Zone{
+		Zone:    "foo.bar",
+		Records: []*dns.ZoneRecord{{Domain: record.Domain, Type: record.Type}},
+	}))
+	require.NoError(t, mock.AddTestCase(http.MethodGet, fmt.Sprintf("zones/%s/%s/%s", record.Zone, record.Domain, record.Type),
+		http.StatusOK, nil, nil, "", record),
+	)
+
+	log := &ns1_internal.CallLog{}
+	worker := NewWorker(mockLogger, ns1_internal.NewRecordingBackend(ns1_internal.NewAPIBackend(mockClient), log), nil, nil, nil)
+	require.NoError(t, worker.RefreshData())
+
+	require.Len(t, worker.zoneCache, 1)
+	require.Equal(t, []int{0, 5}, worker.zoneCache["foo.bar"].NetworkIDs)
+	require.Equal(t, []*dns.Record{record}, worker.recordCache)
+
+	calls := make(map[string]int)
+	for _, call := range log.Calls() {
+		calls[call.Method]++
+	}
+	require.Equal(t, 1, calls[ns1_internal.MethodGetZone])
+	require.Equal(t, 1, calls[ns1_internal.MethodGetRecord])
+}
+
+func TestRefreshDataListFailure(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	require.NoError(t, mock.AddTestCase(http.MethodGet, "zones", http.StatusInternalServerError, nil, nil, "", map[string]string{"message": "internal error"}))
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
+	worker.zoneCache = mockZoneCache
+	worker.recordCache = mockDnsRecordCache
+	worker.RefreshPrometheusTargetData()
+
+	require.Error(t, worker.RefreshData())
+	require.Equal(t, mockZoneCache, worker.zoneCache)
+	require.Equal(t, mockDnsRecordCache, worker.recordCache)
+	require.Equal(t, withZoneLabels(mockSDTargetCache, mockZoneCache["foo.bar"]), worker.targetCache)
+}
+
 func TestRefreshPrometheusTargetData(t *testing.T) {
 	mock, doer, err := mockns1.New(t)
 	require.NoError(t, err)
@@ -297,7 +384,7 @@ func TestRefreshPrometheusTargetData(t *testing.T) {
 	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
 	require.NoError(t, err)
 
-	worker := NewWorker(mockLogger, mockClient, nil, nil, nil)
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
 
 	tests := map[string]struct {
 		recordCache []*dns.Record
@@ -333,7 +420,7 @@ func TestServeHTTP(t *testing.T) {
 	ts := httptest.NewServer(http.DefaultServeMux)
 	t.Cleanup(ts.Close)
 
-	worker := NewWorker(mockLogger, mockClient, nil, nil, nil)
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
 	http.Handle("/sd", worker)
 	httpClient := http.Client{
 		Timeout: 30 * time.Second,
@@ -348,7 +435,9 @@ func TestServeHTTP(t *testing.T) {
 	}
 
 	for name, tc := range tests {
+		// responses are cached per generation of targets
 		worker.targetCache = tc.targetCache
+		worker.publish()
 
 		t.Run(name, func(t *testing.T) {
 			url, err := url.JoinPath(ts.URL, "sd")
@@ -375,3 +464,34 @@ func TestServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	mock, doer, err := mockns1.New(t)
+	require.NoError(t, err)
+	defer mock.Shutdown()
+
+	mockClient := api.NewClient(doer, api.SetAPIKey("mockAPIKey"))
+	mockClient.Endpoint, err = url.Parse(fmt.Sprintf("https://%s/v1/", mock.Address))
+	require.NoError(t, err)
+
+	store, err := storage.New(mockLogger, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { metrics.Registry.Unregister(store) })
+
+	refreshedAt := time.Now().UTC().Truncate(time.Second)
+
+	worker := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
+	worker.Storage = store
+	worker.zoneCache = mockZoneCache
+	worker.recordCache = mockDnsRecordCache
+	worker.SaveSnapshot(refreshedAt)
+
+	restored := NewWorker(mockLogger, ns1_internal.NewAPIBackend(mockClient), nil, nil, nil)
+	restored.Storage = store
+	restored.LoadSnapshot()
+
+	require.Equal(t, mockZoneCache, restored.zoneCache)
+	require.Equal(t, mockDnsRecordCache, restored.recordCache)
+	require.Equal(t, withZoneLabels(mockSDTargetCache, mockZoneCache["foo.bar"]), restored.targetCache)
+	require.True(t, refreshedAt.Equal(restored.lastRefreshTimestamp))
+}
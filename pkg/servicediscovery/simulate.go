@@ -0,0 +1,506 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/filter"
+)
+
+// simulatedFilters are the NS1 filters that can be evaluated locally. Other
+// filters are reported in the trace as not simulated, and leave the answers
+// unchanged.
+var simulatedFilters = map[string]simulatedFilter{
+	"up":                simulateUp,
+	"geotarget_country": simulateGeotargetCountry,
+	"geofence_country":  simulateGeofenceCountry,
+	"weighted_shuffle":  simulateWeightedShuffle,
+	"select_first_n":    simulateSelectFirstN,
+	"priority":          simulatePriority,
+	"netfence_prefix":   simulateNetfencePrefix,
+}
+
+// simulatedFilter evaluates a filter on the answers that are left by the
+// previous filters in the chain, returning the remaining answers and a note
+// explaining the result.
+type simulatedFilter func(client simulatedClient, config filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string)
+
+// simulatedClient is the client that a filter chain is evaluated for.
+type simulatedClient struct {
+	// IP is the client's address, or the address of its ECS subnet.
+	IP      netip.Addr
+	Country string
+	rand    *rand.Rand
+}
+
+// simulatedAnswer is an answer with its effective meta, which is the meta of
+// the answer, falling back to the meta of its region and of its record.
+type simulatedAnswer struct {
+	answer *dns.Answer
+	meta   map[string]string
+}
+
+// FilterSimulator evaluates the filter chain of the records cached by the
+// worker locally, to show which answers NS1 would return to a client in a
+// given country, or with a given address or ECS subnet. Linked records are
+// simulated with the answers and filter chain of the record they link to.
+// Only the filters in simulatedFilters are evaluated, and data feeds aren't
+// followed, so the result is an approximation.
+type FilterSimulator struct {
+	worker *Worker
+}
+
+// NewFilterSimulator creates a filter simulator for the records cached by the
+// worker.
+func NewFilterSimulator(worker *Worker) *FilterSimulator {
+	return &FilterSimulator{worker: worker}
+}
+
+// simulationResponse is the response of the filter simulator.
+type simulationResponse struct {
+	Zone   string `json:"zone"`
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+	// LinkTarget is the domain of the record at the end of the link
+	// chain of a linked record, whose answers are simulated.
+	LinkTarget string               `json:"link_target,omitempty"`
+	ClientIP   string               `json:"client_ip,omitempty"`
+	Country    string               `json:"country,omitempty"`
+	Answers    []simulationAnswer   `json:"answers"`
+	Trace      []simulationTraceRow `json:"trace"`
+}
+
+// simulationAnswer is an answer in the simulator's response.
+type simulationAnswer struct {
+	ID     string            `json:"id"`
+	Rdata  []string          `json:"rdata"`
+	Region string            `json:"region,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+// simulationTraceRow describes the evaluation of a single filter.
+type simulationTraceRow struct {
+	Filter    string        `json:"filter"`
+	Config    filter.Config `json:"config,omitempty"`
+	Disabled  bool          `json:"disabled,omitempty"`
+	Simulated bool          `json:"simulated"`
+	// Answers are the answers left after the filter, identified by their
+	// ID, or by their rdata if they have no ID.
+	Answers []string `json:"answers"`
+	Note    string   `json:"note,omitempty"`
+}
+
+// ServeHTTP implements the http.Handler interface. The record is selected by
+// the `zone`, `domain` and `type` query parameters, and the client by the
+// `client_ip` and `country` query parameters. `client_ip` may be an address or
+// an ECS subnet in CIDR notation. The optional `seed` query parameter makes
+// weighted shuffles reproducible.
+func (s *FilterSimulator) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	zone, domain, recordType := query.Get("zone"), query.Get("domain"), query.Get("type")
+	if zone == "" || domain == "" || recordType == "" {
+		http.Error(writer, "zone, domain and type are required", http.StatusBadRequest)
+		return
+	}
+
+	client, err := parseSimulatedClient(query.Get("client_ip"), query.Get("country"), query.Get("seed"))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	set := s.worker.current()
+	index := slices.IndexFunc(set.records, func(r *dns.Record) bool {
+		return strings.EqualFold(r.Zone, zone) && normalizeDomain(r.Domain) == normalizeDomain(domain) && strings.EqualFold(r.Type, recordType)
+	})
+	if index < 0 {
+		http.Error(writer, "record not found in service discovery record cache", http.StatusNotFound)
+		return
+	}
+
+	record, linkTarget := set.records[index], ""
+	if record.Link != "" {
+		target, err := set.index.resolveLink(record)
+		if err != nil {
+			http.Error(writer, "failed to resolve linked record: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		record, _ = withLinkedAnswers(record, target)
+		linkTarget = target.Domain
+	}
+
+	response := simulateFilterChain(client, record)
+	response.LinkTarget = linkTarget
+	response.ClientIP = query.Get("client_ip")
+
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(response); err != nil {
+		s.worker.logger.Error("Failed to encode filter simulation response", "err", err)
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseSimulatedClient parses the client that a filter chain is simulated
+// for. All values are optional.
+func parseSimulatedClient(rawIP, country, rawSeed string) (simulatedClient, error) {
+	client := simulatedClient{Country: strings.ToUpper(country)}
+
+	if rawIP != "" {
+		if prefix, err := netip.ParsePrefix(rawIP); err == nil {
+			client.IP = prefix.Masked().Addr()
+		} else {
+			client.IP, err = netip.ParseAddr(rawIP)
+			if err != nil {
+				return client, fmt.Errorf("invalid client_ip %q", rawIP)
+			}
+		}
+	}
+
+	seed := uint64(time.Now().UnixNano())
+	if rawSeed != "" {
+		var err error
+		seed, err = strconv.ParseUint(rawSeed, 10, 64)
+		if err != nil {
+			return client, fmt.Errorf("invalid seed %q", rawSeed)
+		}
+	}
+	client.rand = rand.New(rand.NewPCG(seed, seed))
+
+	return client, nil
+}
+
+// simulateFilterChain evaluates the filter chain of a record for a client.
+func simulateFilterChain(client simulatedClient, record *dns.Record) *simulationResponse {
+	recordMeta := metaAsStringMap(record.Meta)
+
+	answers := make([]simulatedAnswer, 0, len(record.Answers))
+	for _, answer := range record.Answers {
+		meta := maps.Clone(recordMeta)
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		if region, ok := record.Regions[answer.RegionName]; ok && answer.RegionName != "" {
+			maps.Copy(meta, metaAsStringMap(&region.Meta))
+		}
+		maps.Copy(meta, metaAsStringMap(answer.Meta))
+
+		answers = append(answers, simulatedAnswer{answer: answer, meta: meta})
+	}
+
+	response := &simulationResponse{
+		Zone:    record.Zone,
+		Domain:  record.Domain,
+		Type:    record.Type,
+		Country: client.Country,
+		Trace:   []simulationTraceRow{},
+	}
+
+	for _, f := range record.Filters {
+		row := simulationTraceRow{
+			Filter:   f.Type,
+			Config:   f.Config,
+			Disabled: f.Disabled,
+		}
+
+		simulate, ok := simulatedFilters[f.Type]
+		switch {
+		case f.Disabled:
+			row.Note = "filter is disabled, answers unchanged"
+		case !ok:
+			row.Note = "filter is not simulated, answers unchanged"
+		default:
+			row.Simulated = true
+			answers, row.Note = simulate(client, f.Config, answers)
+		}
+
+		row.Answers = simulatedAnswerNames(answers)
+		response.Trace = append(response.Trace, row)
+	}
+
+	response.Answers = make([]simulationAnswer, 0, len(answers))
+	for _, a := range answers {
+		response.Answers = append(response.Answers, simulationAnswer{
+			ID:     a.answer.ID,
+			Rdata:  a.answer.Rdata,
+			Region: a.answer.RegionName,
+			Meta:   a.meta,
+		})
+	}
+
+	return response
+}
+
+func simulatedAnswerNames(answers []simulatedAnswer) []string {
+	names := make([]string, 0, len(answers))
+	for _, a := range answers {
+		if a.answer.ID != "" {
+			names = append(names, a.answer.ID)
+			continue
+		}
+		names = append(names, strings.Join(a.answer.Rdata, " "))
+	}
+
+	return names
+}
+
+// metaList returns the values of a list meta key, such as `country`.
+func metaList(meta map[string]string, key string) []string {
+	value, ok := meta[key]
+	if !ok || value == "" {
+		return nil
+	}
+
+	var values []string
+	for v := range strings.SplitSeq(value, ",") {
+		values = append(values, strings.TrimSpace(v))
+	}
+
+	return values
+}
+
+// isFeed returns whether a meta value is a pointer to a data feed, whose
+// value isn't known locally.
+func isFeed(value string) bool {
+	return strings.HasPrefix(value, "{")
+}
+
+// configBool returns a boolean filter config value, which NS1 stores as a
+// boolean, a number or a string.
+func configBool(config filter.Config, key string) bool {
+	switch v := config[key].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case int:
+		return v != 0
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
+
+// configInt returns an integer filter config value, or the default if the
+// value isn't set or isn't a number.
+func configInt(config filter.Config, key string, def int) int {
+	switch v := config[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+
+	return def
+}
+
+// simulateUp removes answers that are down. Answers without `up` meta are up,
+// and answers whose `up` meta is a data feed are assumed to be up.
+func simulateUp(_ simulatedClient, _ filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	var (
+		kept  []simulatedAnswer
+		feeds int
+	)
+	for _, a := range answers {
+		up, ok := a.meta["up"]
+		switch {
+		case !ok:
+		case isFeed(up):
+			feeds++
+		case up == "0" || strings.EqualFold(up, "false"):
+			continue
+		}
+		kept = append(kept, a)
+	}
+
+	note := fmt.Sprintf("removed %d answer(s) that are down", len(answers)-len(kept))
+	if feeds > 0 {
+		note += fmt.Sprintf(", assumed %d answer(s) with a data feed are up", feeds)
+	}
+
+	return kept, note
+}
+
+// simulateGeotargetCountry sorts the answers in the client's country first.
+func simulateGeotargetCountry(client simulatedClient, _ filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	if client.Country == "" {
+		return answers, "no client country, answers unchanged"
+	}
+
+	var matched, others []simulatedAnswer
+	for _, a := range answers {
+		if slices.Contains(metaList(a.meta, "country"), client.Country) {
+			matched = append(matched, a)
+			continue
+		}
+		others = append(others, a)
+	}
+
+	return append(matched, others...), fmt.Sprintf("sorted %d answer(s) in country %s first", len(matched), client.Country)
+}
+
+// simulateGeofenceCountry removes answers that aren't in the client's
+// country. Answers without `country` meta are kept, unless the filter's
+// `remove_no_location` config is set.
+func simulateGeofenceCountry(client simulatedClient, config filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	if client.Country == "" {
+		return answers, "no client country, answers unchanged"
+	}
+
+	removeNoLocation := configBool(config, "remove_no_location")
+	var kept []simulatedAnswer
+	for _, a := range answers {
+		countries := metaList(a.meta, "country")
+		if slices.Contains(countries, client.Country) || (len(countries) == 0 && !removeNoLocation) {
+			kept = append(kept, a)
+		}
+	}
+
+	return kept, fmt.Sprintf("removed %d answer(s) outside of country %s", len(answers)-len(kept), client.Country)
+}
+
+// simulateWeightedShuffle shuffles the answers randomly, with answers of a
+// higher `weight` meta more likely to be sorted first. Answers without a
+// weight have a weight of 1.
+func simulateWeightedShuffle(client simulatedClient, _ filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	remaining := slices.Clone(answers)
+	weights := make([]float64, len(remaining))
+	for i, a := range remaining {
+		weights[i] = 1
+		if w, err := strconv.ParseFloat(a.meta["weight"], 64); err == nil && w >= 0 {
+			weights[i] = w
+		}
+	}
+
+	shuffled := make([]simulatedAnswer, 0, len(answers))
+	for len(remaining) > 0 {
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+
+		// answers with a weight of 0 are only picked once no answer
+		// with a weight is left, in their original order
+		pick := 0
+		if total > 0 {
+			roll := client.rand.Float64() * total
+			for i, w := range weights {
+				if w == 0 {
+					continue
+				}
+				pick = i
+				if roll < w {
+					break
+				}
+				roll -= w
+			}
+		}
+
+		shuffled = append(shuffled, remaining[pick])
+		remaining = slices.Delete(remaining, pick, pick+1)
+		weights = slices.Delete(weights, pick, pick+1)
+	}
+
+	return shuffled, "shuffled answers by weight"
+}
+
+// simulateSelectFirstN keeps the first `N` answers, 1 by default. An `N`
+// below 1 is invalid, and leaves the answers unchanged.
+func simulateSelectFirstN(_ simulatedClient, config filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	n := configInt(config, "N", 1)
+	if n < 1 {
+		return answers, fmt.Sprintf("invalid config: N must be at least 1, got %d, answers unchanged", n)
+	}
+	if n < len(answers) {
+		answers = answers[:n]
+	}
+
+	return answers, fmt.Sprintf("selected the first %d answer(s)", n)
+}
+
+// simulatePriority keeps the answers with the lowest `priority` meta. Answers
+// without a priority are only kept if no answer has a priority.
+func simulatePriority(_ simulatedClient, _ filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	lowest := -1
+	for _, a := range answers {
+		if p, err := strconv.Atoi(a.meta["priority"]); err == nil && (lowest < 0 || p < lowest) {
+			lowest = p
+		}
+	}
+	if lowest < 0 {
+		return answers, "no answer has a priority, answers unchanged"
+	}
+
+	var kept []simulatedAnswer
+	for _, a := range answers {
+		if p, err := strconv.Atoi(a.meta["priority"]); err == nil && p == lowest {
+			kept = append(kept, a)
+		}
+	}
+
+	return kept, fmt.Sprintf("kept %d answer(s) with priority %d", len(kept), lowest)
+}
+
+// simulateNetfencePrefix removes answers whose `ip_prefixes` meta doesn't
+// contain the client's address. Answers without prefixes are kept, unless the
+// filter's `remove_no_ip_prefixes` config is set.
+func simulateNetfencePrefix(client simulatedClient, config filter.Config, answers []simulatedAnswer) ([]simulatedAnswer, string) {
+	if !client.IP.IsValid() {
+		return answers, "no client IP, answers unchanged"
+	}
+
+	removeNoPrefixes := configBool(config, "remove_no_ip_prefixes")
+	var kept []simulatedAnswer
+	for _, a := range answers {
+		prefixes := metaList(a.meta, "ip_prefixes")
+		if len(prefixes) == 0 {
+			if !removeNoPrefixes {
+				kept = append(kept, a)
+			}
+			continue
+		}
+
+		if slices.ContainsFunc(prefixes, func(raw string) bool {
+			prefix, err := netip.ParsePrefix(raw)
+			return err == nil && prefix.Contains(client.IP)
+		}) {
+			kept = append(kept, a)
+		}
+	}
+
+	return kept, fmt.Sprintf("removed %d answer(s) whose prefixes don't contain %s", len(answers)-len(kept), client.IP)
+}
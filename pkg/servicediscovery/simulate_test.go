@@ -0,0 +1,304 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/filter"
+)
+
+// newSimulatedRecord returns a record with answers covering the meta that
+// simulated filters evaluate, and the provided filter chain.
+func newSimulatedRecord(filters ...*filter.Filter) *dns.Record {
+	return &dns.Record{
+		Zone:   "foo.bar",
+		Domain: "www.foo.bar",
+		Type:   "A",
+		Regions: data.Regions{
+			"eu": {Meta: data.Meta{Country: []string{"DE", "FR"}}},
+		},
+		Answers: []*dns.Answer{
+			{ID: "us", Rdata: []string{"1.1.1.1"}, Meta: &data.Meta{Country: []string{"US"}, Up: true, Priority: 1, IPPrefixes: []string{"10.0.0.0/8"}}},
+			{ID: "de", Rdata: []string{"2.2.2.2"}, Meta: &data.Meta{Country: []string{"DE"}, Up: false, Priority: 1}},
+			{ID: "eu", Rdata: []string{"3.3.3.3"}, RegionName: "eu", Meta: &data.Meta{Priority: 2}},
+			{Rdata: []string{"4.4.4.4"}},
+			{ID: "feed", Rdata: []string{"5.5.5.5"}, Meta: &data.Meta{Up: map[string]any{"feed": "abc123"}}},
+		},
+		Filters: filters,
+	}
+}
+
+func TestSimulateFilterChain(t *testing.T) {
+	all := []string{"us", "de", "eu", "4.4.4.4", "feed"}
+
+	tests := map[string]struct {
+		client      string
+		country     string
+		filters     []*filter.Filter
+		want        []string
+		wantTrace   []string
+		wantSkipped bool
+	}{
+		"no_filters": {want: all},
+		"up": {
+			filters: []*filter.Filter{{Type: "up"}},
+			want:    []string{"us", "eu", "4.4.4.4", "feed"},
+		},
+		"geotarget_country": {
+			country: "de",
+			filters: []*filter.Filter{{Type: "geotarget_country"}},
+			// the eu answer is in DE through the meta of its region
+			want: []string{"de", "eu", "us", "4.4.4.4", "feed"},
+		},
+		"geotarget_country_without_country": {
+			filters: []*filter.Filter{{Type: "geotarget_country"}},
+			want:    all,
+		},
+		"geofence_country": {
+			country: "DE",
+			filters: []*filter.Filter{{Type: "geofence_country"}},
+			want:    []string{"de", "eu", "4.4.4.4", "feed"},
+		},
+		"geofence_country_remove_no_location": {
+			country: "DE",
+			filters: []*filter.Filter{{Type: "geofence_country", Config: filter.Config{"remove_no_location": true}}},
+			want:    []string{"de", "eu"},
+		},
+		"geofence_country_without_country": {
+			filters: []*filter.Filter{{Type: "geofence_country", Config: filter.Config{"remove_no_location": true}}},
+			want:    all,
+		},
+		"weighted_shuffle": {
+			// a single answer is left to shuffle, so its order is known
+			filters: []*filter.Filter{{Type: "up"}, {Type: "priority"}, {Type: "weighted_shuffle"}},
+			want:    []string{"us"},
+		},
+		"priority": {
+			filters: []*filter.Filter{{Type: "priority"}},
+			want:    []string{"us", "de"},
+		},
+		"select_first_n": {
+			filters: []*filter.Filter{{Type: "select_first_n", Config: filter.Config{"N": float64(2)}}},
+			want:    []string{"us", "de"},
+		},
+		"select_first_n_default": {
+			filters: []*filter.Filter{{Type: "select_first_n"}},
+			want:    []string{"us"},
+		},
+		"select_first_n_not_a_number": {
+			filters: []*filter.Filter{{Type: "select_first_n", Config: filter.Config{"N": "two"}}},
+			want:    []string{"us"},
+		},
+		"select_first_n_zero": {
+			filters:   []*filter.Filter{{Type: "select_first_n", Config: filter.Config{"N": float64(0)}}},
+			want:      all,
+			wantTrace: []string{"invalid config: N must be at least 1, got 0, answers unchanged"},
+		},
+		"select_first_n_negative": {
+			filters:   []*filter.Filter{{Type: "select_first_n", Config: filter.Config{"N": float64(-1)}}},
+			want:      all,
+			wantTrace: []string{"invalid config: N must be at least 1, got -1, answers unchanged"},
+		},
+		"netfence_prefix": {
+			client:  "10.1.2.3",
+			filters: []*filter.Filter{{Type: "netfence_prefix"}},
+			want:    all,
+		},
+		"netfence_prefix_remove_no_ip_prefixes": {
+			client:  "10.1.2.0/24",
+			filters: []*filter.Filter{{Type: "netfence_prefix", Config: filter.Config{"remove_no_ip_prefixes": "true"}}},
+			want:    []string{"us"},
+		},
+		"netfence_prefix_without_client_ip": {
+			filters: []*filter.Filter{{Type: "netfence_prefix", Config: filter.Config{"remove_no_ip_prefixes": true}}},
+			want:    all,
+		},
+		"netfence_prefix_outside": {
+			client:  "192.168.0.1",
+			filters: []*filter.Filter{{Type: "netfence_prefix", Config: filter.Config{"remove_no_ip_prefixes": true}}},
+			want:    []string{},
+		},
+		"disabled": {
+			filters:     []*filter.Filter{{Type: "up", Disabled: true}},
+			want:        all,
+			wantSkipped: true,
+		},
+		"not_simulated": {
+			filters:     []*filter.Filter{{Type: "shed_load"}},
+			want:        all,
+			wantSkipped: true,
+		},
+		"chain": {
+			country: "DE",
+			filters: []*filter.Filter{{Type: "up"}, {Type: "geotarget_country"}, {Type: "select_first_n"}},
+			want:    []string{"eu"},
+			wantTrace: []string{
+				"removed 1 answer(s) that are down, assumed 1 answer(s) with a data feed are up",
+				"sorted 1 answer(s) in country DE first",
+				"selected the first 1 answer(s)",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := parseSimulatedClient(tc.client, tc.country, "1")
+			require.NoError(t, err)
+
+			got := simulateFilterChain(client, newSimulatedRecord(tc.filters...))
+
+			require.Equal(t, tc.want, simulatedNames(got.Answers))
+			require.Len(t, got.Trace, len(tc.filters))
+			if len(got.Trace) > 0 {
+				last := got.Trace[len(got.Trace)-1]
+				require.Equal(t, tc.want, last.Answers)
+				require.Equal(t, !tc.wantSkipped, last.Simulated)
+			}
+			for i, note := range tc.wantTrace {
+				require.Equal(t, note, got.Trace[i].Note)
+			}
+		})
+	}
+}
+
+func simulatedNames(answers []simulationAnswer) []string {
+	names := []string{}
+	for _, a := range answers {
+		if a.ID != "" {
+			names = append(names, a.ID)
+			continue
+		}
+		names = append(names, a.Rdata[0])
+	}
+
+	return names
+}
+
+func TestSimulateWeightedShuffle(t *testing.T) {
+	record := &dns.Record{
+		Answers: []*dns.Answer{
+			{ID: "never", Rdata: []string{"1.1.1.1"}, Meta: &data.Meta{Weight: float64(0)}},
+			{ID: "default", Rdata: []string{"2.2.2.2"}},
+			{ID: "heavy", Rdata: []string{"3.3.3.3"}, Meta: &data.Meta{Weight: float64(1000000)}},
+		},
+		Filters: []*filter.Filter{{Type: "weighted_shuffle"}},
+	}
+
+	for _, seed := range []string{"1", "2", "3", "4", "5"} {
+		client, err := parseSimulatedClient("", "", seed)
+		require.NoError(t, err)
+		got := simulatedNames(simulateFilterChain(client, record).Answers)
+
+		// answers with a weight of 0 are sorted last
+		require.Equal(t, []string{"heavy", "default", "never"}, got)
+	}
+
+	// the same seed gives the same shuffle
+	record.Answers[2].Meta.Weight = float64(1)
+	clientA, err := parseSimulatedClient("", "", "42")
+	require.NoError(t, err)
+	clientB, err := parseSimulatedClient("", "", "42")
+	require.NoError(t, err)
+	require.Equal(t, simulateFilterChain(clientA, record).Answers, simulateFilterChain(clientB, record).Answers)
+}
+
+func TestFilterSimulatorServeHTTP(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.recordCache = []*dns.Record{
+		newSimulatedRecord(&filter.Filter{Type: "geofence_country"}),
+		{Zone: "keep.me", Domain: "app.keep.me", Type: "A", Link: "www.foo.bar."},
+		{Zone: "keep.me", Domain: "broken.keep.me", Type: "A", Link: "nope.foo.bar"},
+	}
+	worker.RefreshPrometheusTargetData()
+	simulator := NewFilterSimulator(worker)
+
+	tests := map[string]struct {
+		query          string
+		wantCode       int
+		wantDomain     string
+		wantLinkTarget string
+		want           []string
+	}{
+		"ok": {
+			query:      "zone=foo.bar&domain=WWW.foo.bar.&type=a&client_ip=2001:db8::1&country=US",
+			wantCode:   http.StatusOK,
+			wantDomain: "www.foo.bar",
+			want:       []string{"us", "4.4.4.4", "feed"},
+		},
+		// linked records are simulated with the answers and filter
+		// chain of the record they link to
+		"linked": {
+			query:          "zone=keep.me&domain=app.keep.me&type=A&client_ip=2001:db8::1&country=US",
+			wantCode:       http.StatusOK,
+			wantDomain:     "app.keep.me",
+			wantLinkTarget: "www.foo.bar",
+			want:           []string{"us", "4.4.4.4", "feed"},
+		},
+		"unresolvable_link": {
+			query:    "zone=keep.me&domain=broken.keep.me&type=A",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		"missing_params": {
+			query:    "zone=foo.bar&domain=www.foo.bar",
+			wantCode: http.StatusBadRequest,
+		},
+		"invalid_client_ip": {
+			query:    "zone=foo.bar&domain=www.foo.bar&type=A&client_ip=nope",
+			wantCode: http.StatusBadRequest,
+		},
+		"invalid_seed": {
+			query:    "zone=foo.bar&domain=www.foo.bar&type=A&seed=-1",
+			wantCode: http.StatusBadRequest,
+		},
+		"not_found": {
+			query:    "zone=foo.bar&domain=www.foo.bar&type=AAAA",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/ns1/simulate?"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			simulator.ServeHTTP(rec, req)
+			require.Equal(t, tc.wantCode, rec.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			var got simulationResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			require.Equal(t, tc.wantDomain, got.Domain)
+			require.Equal(t, tc.wantLinkTarget, got.LinkTarget)
+			require.Equal(t, "2001:db8::1", got.ClientIP)
+			require.Equal(t, "US", got.Country)
+			require.Equal(t, tc.want, simulatedNames(got.Answers))
+			require.Len(t, got.Trace, 1)
+			require.Equal(t, "geofence_country", got.Trace[0].Filter)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/debug/ns1/simulate", nil)
+	rec := httptest.NewRecorder()
+	simulator.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	promModel "github.com/prometheus/common/model"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+// addPrefixedLabels adds a label for each key in values, with the key
+// sanitized and prefixed. Existing labels are never overwritten, so keys that
+// collide after sanitization are kept only once, using the first key in
+// sorted order.
+func addPrefixedLabels(labels promModel.LabelSet, prefix string, values map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		name := promModel.LabelName(prefix + sanitizeLabelName(key))
+		if _, ok := labels[name]; ok {
+			continue
+		}
+		labels[name] = promModel.LabelValue(values[key])
+	}
+}
+
+// structuredRecordLabels returns the structured labels of a record:
+//
+//   - __meta_ns1_record_meta_<key>
+//   - __meta_ns1_record_filter_<index>_type
+//   - __meta_ns1_record_filter_<index>_disabled
+//   - __meta_ns1_record_filter_<index>_config_<key>
+//   - __meta_ns1_record_answer_<index>_id
+//   - __meta_ns1_record_answer_<index>_region
+//   - __meta_ns1_record_answer_<index>_rdata_<n>
+//   - __meta_ns1_record_answer_<index>_meta_<key>
+//   - __meta_ns1_record_region_<name>_meta_<key>
+//
+// Indexes start at 0 and follow the order of the record's filters, answers
+// and answer rdata. Meta, config and region names are sanitized into valid
+// label names.
+func structuredRecordLabels(record *dns.Record) promModel.LabelSet {
+	labels := promModel.LabelSet{}
+
+	addPrefixedLabels(labels, ns1RecordLabelMeta+"_", metaAsStringMap(record.Meta))
+
+	for i, f := range record.Filters {
+		prefix := fmt.Sprintf("%s_%d_", ns1RecordLabelFilter, i)
+		labels[promModel.LabelName(prefix+"type")] = promModel.LabelValue(f.Type)
+		labels[promModel.LabelName(prefix+"disabled")] = promModel.LabelValue(strconv.FormatBool(f.Disabled))
+
+		config := make(map[string]string, len(f.Config))
+		for k, v := range f.Config {
+			config[k] = fmt.Sprintf("%v", v)
+		}
+		addPrefixedLabels(labels, prefix+"config_", config)
+	}
+
+	for i, answer := range record.Answers {
+		prefix := fmt.Sprintf("%s_%d_", ns1RecordLabelAnswer, i)
+		labels[promModel.LabelName(prefix+"id")] = promModel.LabelValue(answer.ID)
+		labels[promModel.LabelName(prefix+"region")] = promModel.LabelValue(answer.RegionName)
+		for n, rdata := range answer.Rdata {
+			labels[promModel.LabelName(fmt.Sprintf("%srdata_%d", prefix, n))] = promModel.LabelValue(rdata)
+		}
+		addPrefixedLabels(labels, prefix+"meta_", metaAsStringMap(answer.Meta))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(record.Regions)) {
+		meta := record.Regions[name].Meta
+		addPrefixedLabels(labels, ns1RecordLabelRegion+"_"+sanitizeLabelName(name)+"_meta_", metaAsStringMap(&meta))
+	}
+
+	return labels
+}
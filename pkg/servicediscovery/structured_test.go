@@ -0,0 +1,116 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"maps"
+	"testing"
+
+	promModel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/filter"
+)
+
+func TestStructuredRecordLabels(t *testing.T) {
+	tests := map[string]struct {
+		record *dns.Record
+		want   promModel.LabelSet
+	}{
+		"empty": {
+			record: &dns.Record{},
+			want:   promModel.LabelSet{},
+		},
+		"full": {
+			record: &dns.Record{
+				Meta: &data.Meta{Up: true, Note: "primary"},
+				Filters: []*filter.Filter{
+					filter.NewUp(),
+					{Type: "select_first_n", Config: filter.Config{"N": 1, "some-key": "x"}},
+				},
+				Answers: []*dns.Answer{
+					{ID: "a0", Rdata: []string{"1.2.3.4"}, Meta: &data.Meta{Weight: 50}, RegionName: "us-east"},
+					{ID: "a1", Rdata: []string{"10", "mail.foo.bar."}},
+				},
+				Regions: data.Regions{"us-east": {Meta: data.Meta{Country: []string{"US"}}}},
+			},
+			want: promModel.LabelSet{
+				"__meta_ns1_record_meta_note":                   "primary",
+				"__meta_ns1_record_meta_up":                     "1",
+				"__meta_ns1_record_filter_0_type":               "up",
+				"__meta_ns1_record_filter_0_disabled":           "false",
+				"__meta_ns1_record_filter_1_type":               "select_first_n",
+				"__meta_ns1_record_filter_1_disabled":           "false",
+				"__meta_ns1_record_filter_1_config_N":           "1",
+				"__meta_ns1_record_filter_1_config_some_key":    "x",
+				"__meta_ns1_record_answer_0_id":                 "a0",
+				"__meta_ns1_record_answer_0_region":             "us-east",
+				"__meta_ns1_record_answer_0_rdata_0":            "1.2.3.4",
+				"__meta_ns1_record_answer_0_meta_weight":        "50",
+				"__meta_ns1_record_answer_1_id":                 "a1",
+				"__meta_ns1_record_answer_1_region":             "",
+				"__meta_ns1_record_answer_1_rdata_0":            "10",
+				"__meta_ns1_record_answer_1_rdata_1":            "mail.foo.bar.",
+				"__meta_ns1_record_region_us_east_meta_country": "US",
+			},
+		},
+		"sanitized_collision": {
+			record: &dns.Record{
+				Filters: []*filter.Filter{
+					{Type: "mock", Config: filter.Config{"a-b": "first", "a.b": "second"}},
+				},
+			},
+			want: promModel.LabelSet{
+				"__meta_ns1_record_filter_0_type":       "mock",
+				"__meta_ns1_record_filter_0_disabled":   "false",
+				"__meta_ns1_record_filter_0_config_a_b": "first",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := structuredRecordLabels(tc.record)
+			require.Equal(t, tc.want, got)
+			require.NoError(t, got.Validate())
+		})
+	}
+}
+
+func TestRefreshPrometheusTargetDataStructuredLabels(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.TargetOptions.StructuredLabels = true
+	worker.recordCache = mockDnsRecordCache
+
+	worker.RefreshPrometheusTargetData()
+	require.Len(t, worker.targetCache, len(mockSDTargetCache))
+
+	// the delimited string labels are kept alongside the structured labels
+	want := maps.Clone(mockSDTargetCache[0].Labels)
+	maps.Copy(want, promModel.LabelSet{
+		"__meta_ns1_record_meta_up":           "1",
+		"__meta_ns1_record_filter_0_type":     "up",
+		"__meta_ns1_record_filter_0_disabled": "false",
+		"__meta_ns1_record_answer_0_id":       "mockARecordAnswerID",
+		"__meta_ns1_record_answer_0_region":   "",
+		"__meta_ns1_record_answer_0_rdata_0":  "1.2.3.4",
+		"__meta_ns1_record_answer_0_rdata_1":  "5.6.7.8",
+		"__meta_ns1_record_answer_0_rdata_2":  "127.0.0.1",
+		"__meta_ns1_record_answer_0_meta_up":  "1",
+	})
+	require.Equal(t, want, worker.targetCache[0].Labels)
+	require.Equal(t, mockSDTargetCache[0].Targets, worker.targetCache[0].Targets)
+}
@@ -0,0 +1,292 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"text/template"
+
+	promModel "github.com/prometheus/common/model"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+const (
+	ns1AnswerLabelID     = ns1Label + "answer_id"
+	ns1AnswerLabelIndex  = ns1Label + "answer_index"
+	ns1AnswerLabelMeta   = ns1Label + "answer_meta"
+	ns1AnswerLabelRdata  = ns1Label + "answer_rdata"
+	ns1AnswerLabelRegion = ns1Label + "answer_region"
+	ns1RdataLabelIndex   = ns1Label + "rdata_index"
+	ns1RdataLabelValue   = ns1Label + "rdata_value"
+
+	defaultAnswerAddressTemplate = "{{.Host}}"
+)
+
+// defaultAnswerAddress is the parsed defaultAnswerAddressTemplate.
+var defaultAnswerAddress = template.Must(ParseAddressTemplate(defaultAnswerAddressTemplate))
+
+// hostRdataIndex holds the index of the rdata field with the hostname of the
+// answers of record types whose rdata has other fields as well, such as the
+// priority of MX answers or the port of SRV answers.
+var hostRdataIndex = map[string]int{
+	"MX":  1,
+	"SRV": 3,
+}
+
+// answerHost returns the index and value of the rdata field of an answer with
+// its hostname or address. For most record types, that is the first field.
+func answerHost(recordType string, rdata []string) (int, string) {
+	if n, ok := hostRdataIndex[strings.ToUpper(recordType)]; ok && n < len(rdata) {
+		return n, rdata[n]
+	}
+
+	return 0, rdata[0]
+}
+
+// TargetMode determines which targets are created for a record.
+type TargetMode string
+
+const (
+	// TargetModeRecord creates one target per record.
+	TargetModeRecord TargetMode = "record"
+	// TargetModeAnswer creates one target per answer of a record.
+	TargetModeAnswer TargetMode = "answer"
+	// TargetModeRdata creates one target per rdata field of each answer of
+	// a record, such as each IP address of an A record. For record types
+	// whose rdata has fields other than the hostname, such as MX and SRV,
+	// only the hostname field is a target.
+	TargetModeRdata TargetMode = "rdata"
+)
+
+// TargetOptions control how records are converted into Prometheus targets.
+type TargetOptions struct {
+	// StructuredLabels adds an individual label for each meta key, filter
+	// and answer of a record, alongside the delimited string labels, so
+	// that they can be used in relabeling without parsing the delimited
+	// strings with regular expressions.
+	StructuredLabels bool
+	// Mode determines which targets are created for a record. The zero
+	// value is the same as TargetModeRecord.
+	Mode TargetMode
+	// AddressTemplate is executed with an AddressTemplateData to create the
+	// address of each target. If nil, record targets use the address
+	// `<domain>-<type>`, and answer and rdata targets use `{{.Host}}`.
+	AddressTemplate *template.Template
+	// LinkMode determines how linked records are converted into targets.
+	// The zero value is the same as LinkModeInclude.
+	LinkMode LinkMode
+	// ExpandAliases creates answer and rdata targets of CNAME and ALIAS
+	// records for the answers of the A and AAAA records at the end of
+	// their CNAME/ALIAS chains within the cached records, instead of for
+	// their own answers, which are hostnames.
+	ExpandAliases bool
+}
+
+// AddressTemplateData is the data that the address template is executed with.
+// Answer fields are only set for answer and rdata targets. For rdata targets,
+// Rdata only contains the rdata field of the target. Host is the rdata field
+// with the answer's hostname or address, such as the exchange of an MX answer
+// or the target of an SRV answer, and the first rdata field for other types.
+type AddressTemplateData struct {
+	Zone        string
+	Domain      string
+	Type        string
+	AnswerID    string
+	AnswerIndex int
+	Region      string
+	Rdata       []string
+	Rdata0      string
+	Host        string
+}
+
+// ParseTargetMode parses a target mode, returning an error for unknown modes.
+func ParseTargetMode(mode string) (TargetMode, error) {
+	switch m := TargetMode(mode); m {
+	case TargetModeRecord, TargetModeAnswer, TargetModeRdata:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown target mode %q", mode)
+	}
+}
+
+// ParseAddressTemplate parses the text of an address template. An empty text
+// returns a nil template, so that the default address is used.
+func ParseAddressTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("address").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+func executeAddressTemplate(tmpl *template.Template, data AddressTemplateData) (string, error) {
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("failed to execute address template: %w", err)
+	}
+
+	return strings.TrimSpace(builder.String()), nil
+}
+
+// buildTargets converts records into Prometheus targets, labeled with the
+// details of their zones. Links and aliases are resolved against the provided
+// index.
+func buildTargets(logger *slog.Logger, opts TargetOptions, zones map[string]*ns1_internal.Zone, index recordIndex, records []*dns.Record) []*HTTPSDTarget {
+	var targets []*HTTPSDTarget
+	for _, record := range records {
+		targets = append(targets, recordTargets(logger, opts, zones, index, record)...)
+	}
+
+	return targets
+}
+
+// recordTargets converts a record into Prometheus targets, according to the
+// target options. Answers without rdata, and targets whose address can't be
+// created, are logged and skipped. When links are resolved, linked records
+// whose link chain can't be resolved are logged and converted as they are.
+func recordTargets(logger *slog.Logger, opts TargetOptions, zones map[string]*ns1_internal.Zone, index recordIndex, record *dns.Record) []*HTTPSDTarget {
+	var linkLabels promModel.LabelSet
+	if record.Link != "" {
+		switch opts.LinkMode {
+		case LinkModeSuppress:
+			logger.Debug("Skipping linked record", "record_domain", record.Domain, "record_type", record.Type, "record_link", record.Link)
+			return nil
+		case LinkModeResolve:
+			target, err := index.resolveLink(record)
+			if err != nil {
+				logger.Warn("Failed to resolve linked record", "err", err, "record_domain", record.Domain, "record_type", record.Type, "record_link", record.Link)
+				break
+			}
+			record, linkLabels = withLinkedAnswers(record, target)
+		}
+	}
+
+	recordTarget := recordAsPrometheusTarget(record)
+	maps.Copy(recordTarget.Labels, linkLabels)
+	if zone, ok := zones[record.Zone]; ok {
+		maps.Copy(recordTarget.Labels, zoneLabels(zone))
+	}
+	if opts.StructuredLabels {
+		maps.Copy(recordTarget.Labels, structuredRecordLabels(record))
+	}
+
+	data := AddressTemplateData{
+		Zone:   record.Zone,
+		Domain: record.Domain,
+		Type:   record.Type,
+	}
+
+	tmpl := opts.AddressTemplate
+	if tmpl == nil && opts.Mode != "" && opts.Mode != TargetModeRecord {
+		tmpl = defaultAnswerAddress
+	}
+
+	var targets []*HTTPSDTarget
+	addTarget := func(data AddressTemplateData, labels promModel.LabelSet) {
+		address, err := executeAddressTemplate(tmpl, data)
+		if err != nil {
+			logger.Warn("Skipping target", "err", err, "record_domain", record.Domain, "record_type", record.Type, "answer_id", data.AnswerID)
+			return
+		}
+		if address == "" {
+			logger.Debug("Skipping target with empty address", "record_domain", record.Domain, "record_type", record.Type, "answer_id", data.AnswerID)
+			return
+		}
+		targets = append(targets, &HTTPSDTarget{Targets: []string{address}, Labels: labels})
+	}
+
+	switch opts.Mode {
+	case TargetModeAnswer, TargetModeRdata:
+		var answers []targetAnswer
+		if opts.ExpandAliases && isAliasRecord(record) {
+			answers = index.expandAliases(logger, record)
+		} else {
+			for _, answer := range record.Answers {
+				answers = append(answers, targetAnswer{answer: answer})
+			}
+		}
+
+		for i, ta := range answers {
+			answer := ta.answer
+			if len(answer.Rdata) == 0 {
+				logger.Debug("Skipping answer without rdata", "record_domain", record.Domain, "record_type", record.Type, "answer_id", answer.ID)
+				continue
+			}
+
+			answerData := data
+			answerData.AnswerID = answer.ID
+			answerData.AnswerIndex = i
+			answerData.Region = answer.RegionName
+
+			answerLabels := maps.Clone(recordTarget.Labels)
+			answerLabels[ns1AnswerLabelID] = promModel.LabelValue(answer.ID)
+			answerLabels[ns1AnswerLabelIndex] = promModel.LabelValue(strconv.Itoa(i))
+			answerLabels[ns1AnswerLabelRegion] = promModel.LabelValue(answer.RegionName)
+			for n, rdata := range answer.Rdata {
+				answerLabels[promModel.LabelName(fmt.Sprintf("%s_%d", ns1AnswerLabelRdata, n))] = promModel.LabelValue(rdata)
+			}
+			addPrefixedLabels(answerLabels, ns1AnswerLabelMeta+"_", metaAsStringMap(answer.Meta))
+			if ta.chain != nil {
+				answerLabels[ns1AnswerLabelChain] = promModel.LabelValue("," + strings.Join(ta.chain, ",") + ",")
+			}
+
+			hostIndex, host := answerHost(record.Type, answer.Rdata)
+			if opts.Mode == TargetModeAnswer {
+				answerData.Rdata = answer.Rdata
+				answerData.Rdata0 = answer.Rdata[0]
+				answerData.Host = host
+				addTarget(answerData, answerLabels)
+				continue
+			}
+
+			_, multiField := hostRdataIndex[strings.ToUpper(record.Type)]
+			for n, rdata := range answer.Rdata {
+				// of rdata with other fields, only the hostname is a
+				// target
+				if multiField && n != hostIndex {
+					continue
+				}
+
+				rdataData := answerData
+				rdataData.Rdata = []string{rdata}
+				rdataData.Rdata0 = rdata
+				rdataData.Host = rdata
+
+				rdataLabels := maps.Clone(answerLabels)
+				rdataLabels[ns1RdataLabelIndex] = promModel.LabelValue(strconv.Itoa(n))
+				rdataLabels[ns1RdataLabelValue] = promModel.LabelValue(rdata)
+				addTarget(rdataData, rdataLabels)
+			}
+		}
+	default:
+		if tmpl == nil {
+			return []*HTTPSDTarget{recordTarget}
+		}
+		addTarget(data, recordTarget.Labels)
+	}
+
+	return targets
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"testing"
+
+	promModel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+)
+
+func TestParseTargetMode(t *testing.T) {
+	tests := map[string]struct {
+		mode    string
+		want    TargetMode
+		wantErr bool
+	}{
+		"record":  {mode: "record", want: TargetModeRecord},
+		"answer":  {mode: "answer", want: TargetModeAnswer},
+		"rdata":   {mode: "rdata", want: TargetModeRdata},
+		"unknown": {mode: "zone", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseTargetMode(tc.mode)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseAddressTemplate(t *testing.T) {
+	tmpl, err := ParseAddressTemplate("")
+	require.NoError(t, err)
+	require.Nil(t, tmpl)
+
+	_, err = ParseAddressTemplate("{{.Rdata0")
+	require.Error(t, err)
+
+	tmpl, err = ParseAddressTemplate("{{.Rdata0}}:9100")
+	require.NoError(t, err)
+	got, err := executeAddressTemplate(tmpl, AddressTemplateData{Rdata0: "1.2.3.4"})
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4:9100", got)
+
+	// unknown fields fail when the template is executed
+	tmpl, err = ParseAddressTemplate("{{.Nope}}")
+	require.NoError(t, err)
+	_, err = executeAddressTemplate(tmpl, AddressTemplateData{})
+	require.Error(t, err)
+}
+
+func TestRecordTargets(t *testing.T) {
+	record := &dns.Record{
+		Zone:   "foo.bar",
+		Domain: "test.foo.bar",
+		Type:   "A",
+		Answers: []*dns.Answer{
+			{ID: "a0", Rdata: []string{"1.2.3.4"}, Meta: &data.Meta{Up: true}, RegionName: "us-east"},
+			{ID: "a1", Rdata: []string{"5.6.7.8", "9.10.11.12"}},
+			{ID: "a2"},
+		},
+	}
+	recordLabels := recordAsPrometheusTarget(record).Labels
+
+	withLabels := recordLabels.Merge
+	answer0Labels := promModel.LabelSet{
+		"__meta_ns1_answer_id":      "a0",
+		"__meta_ns1_answer_index":   "0",
+		"__meta_ns1_answer_region":  "us-east",
+		"__meta_ns1_answer_rdata_0": "1.2.3.4",
+		"__meta_ns1_answer_meta_up": "1",
+	}
+	answer1Labels := promModel.LabelSet{
+		"__meta_ns1_answer_id":      "a1",
+		"__meta_ns1_answer_index":   "1",
+		"__meta_ns1_answer_region":  "",
+		"__meta_ns1_answer_rdata_0": "5.6.7.8",
+		"__meta_ns1_answer_rdata_1": "9.10.11.12",
+	}
+
+	tests := map[string]struct {
+		mode     TargetMode
+		template string
+		want     []*HTTPSDTarget
+	}{
+		"default": {
+			want: []*HTTPSDTarget{{Targets: []string{"test.foo.bar-A"}, Labels: recordLabels}},
+		},
+		"record_template": {
+			mode:     TargetModeRecord,
+			template: "{{.Domain}}:443",
+			want:     []*HTTPSDTarget{{Targets: []string{"test.foo.bar:443"}, Labels: recordLabels}},
+		},
+		"answer": {
+			mode:     TargetModeAnswer,
+			template: "{{.Rdata0}}:9100",
+			// the answer without rdata is skipped
+			want: []*HTTPSDTarget{
+				{Targets: []string{"1.2.3.4:9100"}, Labels: withLabels(answer0Labels)},
+				{Targets: []string{"5.6.7.8:9100"}, Labels: withLabels(answer1Labels)},
+			},
+		},
+		"answer_default_template": {
+			mode: TargetModeAnswer,
+			want: []*HTTPSDTarget{
+				{Targets: []string{"1.2.3.4"}, Labels: withLabels(answer0Labels)},
+				{Targets: []string{"5.6.7.8"}, Labels: withLabels(answer1Labels)},
+			},
+		},
+		"rdata": {
+			mode:     TargetModeRdata,
+			template: "{{.Rdata0}}:9100",
+			want: []*HTTPSDTarget{
+				{Targets: []string{"1.2.3.4:9100"}, Labels: withLabels(answer0Labels.Merge(promModel.LabelSet{"__meta_ns1_rdata_index": "0", "__meta_ns1_rdata_value": "1.2.3.4"}))},
+				{Targets: []string{"5.6.7.8:9100"}, Labels: withLabels(answer1Labels.Merge(promModel.LabelSet{"__meta_ns1_rdata_index": "0", "__meta_ns1_rdata_value": "5.6.7.8"}))},
+				{Targets: []string{"9.10.11.12:9100"}, Labels: withLabels(answer1Labels.Merge(promModel.LabelSet{"__meta_ns1_rdata_index": "1", "__meta_ns1_rdata_value": "9.10.11.12"}))},
+			},
+		},
+		"template_error": {
+			mode:     TargetModeAnswer,
+			template: "{{.Nope}}",
+			want:     nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := ParseAddressTemplate(tc.template)
+			require.NoError(t, err)
+
+			opts := TargetOptions{Mode: tc.mode, AddressTemplate: tmpl}
+			require.Equal(t, tc.want, recordTargets(mockLogger, opts, nil, nil, record))
+		})
+	}
+}
+
+func TestRecordTargetsHostField(t *testing.T) {
+	tests := map[string]struct {
+		record *dns.Record
+		mode   TargetMode
+		want   []string
+	}{
+		"mx_answer": {
+			record: &dns.Record{Domain: "foo.bar", Type: "MX", Answers: []*dns.Answer{{Rdata: []string{"10", "mail.foo.bar"}}}},
+			mode:   TargetModeAnswer,
+			want:   []string{"mail.foo.bar"},
+		},
+		"mx_rdata": {
+			record: &dns.Record{Domain: "foo.bar", Type: "MX", Answers: []*dns.Answer{{Rdata: []string{"10", "mail.foo.bar"}}}},
+			mode:   TargetModeRdata,
+			want:   []string{"mail.foo.bar"},
+		},
+		"srv_rdata": {
+			record: &dns.Record{Domain: "_sip._tcp.foo.bar", Type: "SRV", Answers: []*dns.Answer{{Rdata: []string{"10", "5", "5060", "sip.foo.bar"}}}},
+			mode:   TargetModeRdata,
+			want:   []string{"sip.foo.bar"},
+		},
+		"truncated_mx": {
+			record: &dns.Record{Domain: "foo.bar", Type: "MX", Answers: []*dns.Answer{{Rdata: []string{"mail.foo.bar"}}}},
+			mode:   TargetModeAnswer,
+			want:   []string{"mail.foo.bar"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, target := range recordTargets(mockLogger, TargetOptions{Mode: tc.mode}, nil, nil, tc.record) {
+				got = append(got, target.Targets...)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	// the other fields are still available to templates
+	tmpl, err := ParseAddressTemplate("{{.Host}}:{{index .Rdata 2}}")
+	require.NoError(t, err)
+	record := &dns.Record{Domain: "_sip._tcp.foo.bar", Type: "SRV", Answers: []*dns.Answer{{Rdata: []string{"10", "5", "5060", "sip.foo.bar"}}}}
+	targets := recordTargets(mockLogger, TargetOptions{Mode: TargetModeAnswer, AddressTemplate: tmpl}, nil, nil, record)
+	require.Len(t, targets, 1)
+	require.Equal(t, []string{"sip.foo.bar:5060"}, targets[0].Targets)
+}
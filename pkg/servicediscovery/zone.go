@@ -0,0 +1,60 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"strconv"
+	"strings"
+
+	promModel "github.com/prometheus/common/model"
+
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+const (
+	ns1ZoneLabelDNSSEC    = ns1Label + "zone_dnssec_enabled"
+	ns1ZoneLabelNetworks  = ns1Label + "zone_networks"
+	ns1ZoneLabelPrimary   = ns1Label + "zone_primary_enabled"
+	ns1ZoneLabelSecondary = ns1Label + "zone_secondary_enabled"
+	ns1ZoneLabelTag       = ns1Label + "zone_tag_"
+	ns1ZoneLabelTTL       = ns1Label + "zone_ttl"
+	ns1ZoneLabelViews     = ns1Label + "zone_views"
+)
+
+// zoneLabels returns the labels describing a record's zone:
+//
+//   - __meta_ns1_zone_ttl
+//   - __meta_ns1_zone_dnssec_enabled
+//   - __meta_ns1_zone_primary_enabled
+//   - __meta_ns1_zone_secondary_enabled
+//   - __meta_ns1_zone_networks, as a comma separated list of network IDs
+//     with leading and trailing commas
+//   - __meta_ns1_zone_views, as a comma separated list of view names with
+//     leading and trailing commas, which is empty unless view lookup is
+//     enabled
+//   - __meta_ns1_zone_tag_<key>
+func zoneLabels(zone *ns1_internal.Zone) promModel.LabelSet {
+	labels := promModel.LabelSet{
+		ns1ZoneLabelDNSSEC:    promModel.LabelValue(strconv.FormatBool(zone.DNSSEC)),
+		ns1ZoneLabelNetworks:  promModel.LabelValue("," + ns1_internal.JoinNetworkIDs(zone.NetworkIDs) + ","),
+		ns1ZoneLabelPrimary:   promModel.LabelValue(strconv.FormatBool(zone.Primary)),
+		ns1ZoneLabelSecondary: promModel.LabelValue(strconv.FormatBool(zone.Secondary)),
+		ns1ZoneLabelTTL:       promModel.LabelValue(strconv.Itoa(zone.TTL)),
+		ns1ZoneLabelViews:     promModel.LabelValue("," + strings.Join(zone.Views, ",") + ","),
+	}
+	addPrefixedLabels(labels, ns1ZoneLabelTag, zone.Tags)
+
+	return labels
+}
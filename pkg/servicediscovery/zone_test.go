@@ -0,0 +1,99 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicediscovery
+
+import (
+	"testing"
+
+	promModel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	ns1_internal "github.com/tjhop/ns1_exporter/pkg/ns1"
+)
+
+func TestZoneLabels(t *testing.T) {
+	tests := map[string]struct {
+		zone *ns1_internal.Zone
+		want promModel.LabelSet
+	}{
+		"empty": {
+			zone: &ns1_internal.Zone{Zone: "foo.bar"},
+			want: promModel.LabelSet{
+				"__meta_ns1_zone_dnssec_enabled":    "false",
+				"__meta_ns1_zone_networks":          ",,",
+				"__meta_ns1_zone_primary_enabled":   "false",
+				"__meta_ns1_zone_secondary_enabled": "false",
+				"__meta_ns1_zone_ttl":               "0",
+				"__meta_ns1_zone_views":             ",,",
+			},
+		},
+		"full": {
+			zone: &ns1_internal.Zone{
+				Zone:       "foo.bar",
+				NetworkIDs: []int{4, 0},
+				Views:      []string{"external", "internal"},
+				Tags:       map[string]string{"env": "prod", "cost-center": "dns"},
+				TTL:        3600,
+				DNSSEC:     true,
+				Primary:    true,
+			},
+			want: promModel.LabelSet{
+				"__meta_ns1_zone_dnssec_enabled":    "true",
+				"__meta_ns1_zone_networks":          ",0,4,",
+				"__meta_ns1_zone_primary_enabled":   "true",
+				"__meta_ns1_zone_secondary_enabled": "false",
+				"__meta_ns1_zone_tag_cost_center":   "dns",
+				"__meta_ns1_zone_tag_env":           "prod",
+				"__meta_ns1_zone_ttl":               "3600",
+				"__meta_ns1_zone_views":             ",external,internal,",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, zoneLabels(tc.zone))
+		})
+	}
+}
+
+func TestRefreshPrometheusTargetDataZoneLabels(t *testing.T) {
+	worker := NewWorker(mockLogger, nil, nil, nil, nil)
+	worker.recordCache = mockDnsRecordCache
+	worker.zoneCache = map[string]*ns1_internal.Zone{
+		"foo.bar": {Zone: "foo.bar", TTL: 3600, DNSSEC: true},
+	}
+	worker.RefreshPrometheusTargetData()
+
+	require.Equal(t, withZoneLabels(mockSDTargetCache, worker.zoneCache["foo.bar"]), worker.targetCache)
+
+	// records of zones that aren't cached have no zone labels
+	worker.zoneCache = nil
+	worker.RefreshPrometheusTargetData()
+	require.Equal(t, mockSDTargetCache, worker.targetCache)
+}
+
+// withZoneLabels returns copies of targets with the labels of their zone.
+func withZoneLabels(targets []*HTTPSDTarget, zone *ns1_internal.Zone) []*HTTPSDTarget {
+	labeled := make([]*HTTPSDTarget, 0, len(targets))
+	for _, target := range targets {
+		labeled = append(labeled, &HTTPSDTarget{
+			Targets: target.Targets,
+			Labels:  target.Labels.Merge(zoneLabels(zone)),
+		})
+	}
+
+	return labeled
+}
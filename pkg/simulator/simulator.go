@@ -0,0 +1,498 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator implements a fake NS1 REST API that serves a configurable
+// set of zones, records, QPS stats and account activity, for running the
+// exporter locally or in CI without access to the real NS1 API.
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/ns1/ns1-go.v2/rest/model/account"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	"gopkg.in/yaml.v3"
+)
+
+const apiKeyHeader = "X-NSONE-Key"
+
+// QPSCurve describes a sine wave of QPS values over time, with optional
+// random noise. Value = Base + Amplitude * sin(2π * t / Period), plus up to
+// Noise * Base of random jitter in either direction.
+type QPSCurve struct {
+	Base      float64       `json:"base"`
+	Amplitude float64       `json:"amplitude"`
+	Period    time.Duration `json:"period"`
+	Noise     float64       `json:"noise"`
+}
+
+// UnmarshalJSON allows the curve's period to be configured as a duration
+// string such as `1h`.
+func (c *QPSCurve) UnmarshalJSON(data []byte) error {
+	type plain QPSCurve
+	var raw struct {
+		plain
+		Period string `json:"period"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*c = QPSCurve(raw.plain)
+	if raw.Period != "" {
+		period, err := time.ParseDuration(raw.Period)
+		if err != nil {
+			return fmt.Errorf("invalid qps period: %w", err)
+		}
+		c.Period = period
+	}
+
+	return nil
+}
+
+// Value returns the value of the curve at the given time.
+func (c *QPSCurve) Value(t time.Time) float64 {
+	value := c.Base
+	if c.Period > 0 {
+		phase := float64(t.UnixNano()%int64(c.Period)) / float64(c.Period)
+		value += c.Amplitude * math.Sin(2*math.Pi*phase)
+	}
+	if c.Noise > 0 {
+		value += c.Base * c.Noise * (2*rand.Float64() - 1)
+	}
+
+	return math.Max(value, 0)
+}
+
+// RecordConfig configures a simulated record. The embedded record uses the
+// same field names as the NS1 API, so answers, filters, regions and meta can
+// be copied from real API responses.
+type RecordConfig struct {
+	dns.Record
+
+	QPS *QPSCurve `json:"qps,omitempty"`
+}
+
+// ZoneConfig configures a simulated zone and its records.
+type ZoneConfig struct {
+	Zone       string            `json:"zone"`
+	TTL        int               `json:"ttl"`
+	DNSSEC     bool              `json:"dnssec"`
+	NetworkIDs []int             `json:"networks"`
+	Tags       map[string]string `json:"tags"`
+	QPS        *QPSCurve         `json:"qps,omitempty"`
+	Records    []*RecordConfig   `json:"records"`
+}
+
+// ActivityConfig configures a recurring account activity event.
+type ActivityConfig struct {
+	ResourceType string        `json:"resource_type"`
+	Action       string        `json:"action"`
+	Interval     time.Duration `json:"interval"`
+}
+
+// UnmarshalJSON allows the activity interval to be configured as a duration
+// string such as `5m`.
+func (a *ActivityConfig) UnmarshalJSON(data []byte) error {
+	type plain ActivityConfig
+	var raw struct {
+		plain
+		Interval string `json:"interval"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*a = ActivityConfig(raw.plain)
+	if raw.Interval != "" {
+		interval, err := time.ParseDuration(raw.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid activity interval: %w", err)
+		}
+		a.Interval = interval
+	}
+
+	return nil
+}
+
+// FaultConfig configures errors injected into API responses.
+type FaultConfig struct {
+	// RateLimitProbability is the probability [0, 1] that a request is
+	// answered with HTTP 429.
+	RateLimitProbability float64 `json:"rate_limit_probability"`
+	// ServerErrorProbability is the probability [0, 1] that a request is
+	// answered with ServerErrorStatus.
+	ServerErrorProbability float64 `json:"server_error_probability"`
+	// ServerErrorStatus is the HTTP status code used for injected server
+	// errors. Defaults to 500.
+	ServerErrorStatus int `json:"server_error_status"`
+}
+
+// RateLimitConfig configures the values of the rate limit headers returned
+// with every response, which drive the NS1 Go SDK's rate limiting strategy.
+type RateLimitConfig struct {
+	Limit  int `json:"limit"`
+	Period int `json:"period"`
+}
+
+// Config is the configuration of the simulator.
+type Config struct {
+	// APIKey is optional. When set, requests with a different API key are
+	// rejected with HTTP 401.
+	APIKey    string            `json:"api_key"`
+	RateLimit RateLimitConfig   `json:"rate_limit"`
+	Faults    FaultConfig       `json:"faults"`
+	QPS       *QPSCurve         `json:"qps,omitempty"`
+	Zones     []*ZoneConfig     `json:"zones"`
+	Views     []*dns.View       `json:"views"`
+	Activity  []*ActivityConfig `json:"activity"`
+}
+
+// LoadConfig reads a simulator config from a YAML file.
+func LoadConfig(path string) (*Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(buf)
+}
+
+// ParseConfig parses a simulator config from YAML. The YAML is converted to
+// JSON before decoding so that records can reuse the JSON field names of the
+// NS1 API models.
+func ParseConfig(buf []byte) (*Config, error) {
+	var raw any
+	if err := yaml.Unmarshal(buf, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse simulator config: %w", err)
+	}
+
+	jsonBuf, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert simulator config: %w", err)
+	}
+
+	config := &Config{
+		RateLimit: RateLimitConfig{Limit: 1000, Period: 1},
+	}
+	if err := json.Unmarshal(jsonBuf, config); err != nil {
+		return nil, fmt.Errorf("failed to decode simulator config: %w", err)
+	}
+
+	if config.Faults.ServerErrorStatus == 0 {
+		config.Faults.ServerErrorStatus = http.StatusInternalServerError
+	}
+
+	for _, z := range config.Zones {
+		if z.Zone == "" {
+			return nil, errors.New("zone name must not be empty")
+		}
+		for _, r := range z.Records {
+			r.Zone = z.Zone
+			if r.Domain == "" || r.Type == "" {
+				return nil, fmt.Errorf("records in zone %s must have a domain and type", z.Zone)
+			}
+			if r.ID == "" {
+				r.ID = fmt.Sprintf("sim-%s-%s", r.Domain, r.Type)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// Simulator is an http.Handler serving a fake NS1 REST API under `/v1/`.
+type Simulator struct {
+	config  *Config
+	logger  *slog.Logger
+	mux     *http.ServeMux
+	started time.Time
+	zones   map[string]*ZoneConfig
+}
+
+// New creates a new Simulator from the provided config.
+func New(logger *slog.Logger, config *Config) *Simulator {
+	s := &Simulator{
+		config:  config,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+		zones:   make(map[string]*ZoneConfig),
+	}
+
+	for _, z := range config.Zones {
+		s.zones[z.Zone] = z
+	}
+
+	s.mux.HandleFunc("GET /v1/zones", s.handleListZones)
+	s.mux.HandleFunc("GET /v1/zones/{zone}", s.handleGetZone)
+	s.mux.HandleFunc("GET /v1/zones/{zone}/{domain}/{type}", s.handleGetRecord)
+	s.mux.HandleFunc("GET /v1/views", s.handleListViews)
+	s.mux.HandleFunc("GET /v1/stats/qps", s.handleGetQPS)
+	s.mux.HandleFunc("GET /v1/stats/qps/{zone}", s.handleGetZoneQPS)
+	s.mux.HandleFunc("GET /v1/stats/qps/{zone}/{domain}/{type}", s.handleGetRecordQPS)
+	s.mux.HandleFunc("GET /v1/account/activity", s.handleListActivity)
+
+	return s
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.logger.Debug("Simulated NS1 API request", "method", req.Method, "path", req.URL.Path)
+
+	w.Header().Set("X-Ratelimit-Limit", strconv.Itoa(s.config.RateLimit.Limit))
+	w.Header().Set("X-Ratelimit-Remaining", strconv.Itoa(s.config.RateLimit.Limit))
+	w.Header().Set("X-Ratelimit-Period", strconv.Itoa(s.config.RateLimit.Period))
+
+	if s.config.APIKey != "" && req.Header.Get(apiKeyHeader) != s.config.APIKey {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch roll := rand.Float64(); {
+	case roll < s.config.Faults.RateLimitProbability:
+		w.Header().Set("X-Ratelimit-Remaining", "0")
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	case roll < s.config.Faults.RateLimitProbability+s.config.Faults.ServerErrorProbability:
+		writeError(w, s.config.Faults.ServerErrorStatus, "simulated server error")
+		return
+	}
+
+	s.mux.ServeHTTP(w, req)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+	}{Message: message})
+}
+
+func (s *Simulator) zone(name string) (*ZoneConfig, bool) {
+	z, ok := s.zones[name]
+	return z, ok
+}
+
+func (s *Simulator) record(zoneName, domain, recordType string) (*ZoneConfig, *RecordConfig, bool) {
+	z, ok := s.zone(zoneName)
+	if !ok {
+		return nil, nil, false
+	}
+
+	for _, r := range z.Records {
+		if r.Domain == domain && r.Type == recordType {
+			return z, r, true
+		}
+	}
+
+	return z, nil, false
+}
+
+func (z *ZoneConfig) apiZone(withRecords bool) *dns.Zone {
+	dnssec := z.DNSSEC
+	zone := &dns.Zone{
+		ID:         "sim-" + z.Zone,
+		Zone:       z.Zone,
+		TTL:        z.TTL,
+		NetworkIDs: z.NetworkIDs,
+		DNSSEC:     &dnssec,
+		Tags:       z.Tags,
+	}
+
+	if withRecords {
+		for _, r := range z.Records {
+			var shortAns []string
+			for _, a := range r.Answers {
+				if len(a.Rdata) > 0 {
+					shortAns = append(shortAns, a.Rdata[0])
+				}
+			}
+
+			zone.Records = append(zone.Records, &dns.ZoneRecord{
+				ID:       r.ID,
+				Domain:   r.Domain,
+				Type:     r.Type,
+				TTL:      r.TTL,
+				Link:     r.Link,
+				ShortAns: shortAns,
+				Tags:     r.Tags,
+			})
+		}
+	}
+
+	return zone
+}
+
+func (s *Simulator) handleListZones(w http.ResponseWriter, _ *http.Request) {
+	zones := []*dns.Zone{}
+	for _, z := range s.config.Zones {
+		zones = append(zones, z.apiZone(false))
+	}
+
+	writeJSON(w, zones)
+}
+
+func (s *Simulator) handleGetZone(w http.ResponseWriter, req *http.Request) {
+	z, ok := s.zone(req.PathValue("zone"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "zone not found")
+		return
+	}
+
+	writeJSON(w, z.apiZone(req.URL.Query().Get("records") != "false"))
+}
+
+func (s *Simulator) handleGetRecord(w http.ResponseWriter, req *http.Request) {
+	_, r, ok := s.record(req.PathValue("zone"), req.PathValue("domain"), req.PathValue("type"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	writeJSON(w, &r.Record)
+}
+
+func (s *Simulator) handleListViews(w http.ResponseWriter, _ *http.Request) {
+	views := s.config.Views
+	if views == nil {
+		views = []*dns.View{}
+	}
+
+	writeJSON(w, views)
+}
+
+func (s *Simulator) recordQPS(r *RecordConfig, now time.Time) float64 {
+	switch {
+	case r.QPS != nil:
+		return r.QPS.Value(now)
+	case s.config.QPS != nil:
+		return s.config.QPS.Value(now)
+	default:
+		return 0
+	}
+}
+
+func (s *Simulator) zoneQPS(z *ZoneConfig, now time.Time) float64 {
+	if z.QPS != nil {
+		return z.QPS.Value(now)
+	}
+
+	var total float64
+	for _, r := range z.Records {
+		total += s.recordQPS(r, now)
+	}
+
+	return total
+}
+
+func writeQPS(w http.ResponseWriter, qps float64) {
+	writeJSON(w, struct {
+		QPS float64 `json:"qps"`
+	}{QPS: qps})
+}
+
+func (s *Simulator) handleGetQPS(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+
+	var total float64
+	for _, z := range s.config.Zones {
+		total += s.zoneQPS(z, now)
+	}
+
+	writeQPS(w, total)
+}
+
+func (s *Simulator) handleGetZoneQPS(w http.ResponseWriter, req *http.Request) {
+	z, ok := s.zone(req.PathValue("zone"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "zone not found")
+		return
+	}
+
+	writeQPS(w, s.zoneQPS(z, time.Now()))
+}
+
+func (s *Simulator) handleGetRecordQPS(w http.ResponseWriter, req *http.Request) {
+	z, r, ok := s.record(req.PathValue("zone"), req.PathValue("domain"), req.PathValue("type"))
+	switch {
+	case z == nil:
+		writeError(w, http.StatusNotFound, "zone not found")
+		return
+	case !ok:
+		writeError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	writeQPS(w, s.recordQPS(r, time.Now()))
+}
+
+// handleListActivity returns the most recent occurrence of each configured
+// recurring activity event, if it happened after the requested start time.
+func (s *Simulator) handleListActivity(w http.ResponseWriter, req *http.Request) {
+	var start int64
+	if v := req.URL.Query().Get("start"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid start parameter")
+			return
+		}
+		start = parsed
+	}
+
+	now := time.Now()
+	activity := []*account.Activity{}
+	for i, a := range s.config.Activity {
+		if a.Interval <= 0 {
+			continue
+		}
+
+		occurrences := now.Sub(s.started) / a.Interval
+		if occurrences == 0 {
+			continue
+		}
+
+		ts := s.started.Add(occurrences * a.Interval).Unix()
+		if ts < start {
+			continue
+		}
+
+		activity = append(activity, &account.Activity{
+			ID:           fmt.Sprintf("sim-activity-%d-%d", i, occurrences),
+			ResourceType: a.ResourceType,
+			Action:       a.Action,
+			Timestamp:    int(ts),
+			UserName:     "ns1_simulator",
+		})
+	}
+
+	writeJSON(w, activity)
+}
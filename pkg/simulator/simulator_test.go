@@ -0,0 +1,175 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/require"
+	api "gopkg.in/ns1/ns1-go.v2/rest"
+)
+
+var (
+	mockLogger = promslog.New(&promslog.Config{})
+
+	mockConfig = []byte(`
+api_key: mockAPIKey
+zones:
+  - zone: foo.bar
+    ttl: 3600
+    dnssec: true
+    tags:
+      team: dns
+    records:
+      - domain: test.foo.bar
+        type: A
+        answers:
+          - answer: ["1.2.3.4"]
+            meta:
+              up: true
+        filters:
+          - filter: up
+            config: {}
+        qps:
+          base: 100
+      - domain: test.foo.bar
+        type: AAAA
+        answers:
+          - answer: ["dead::beef"]
+        qps:
+          base: 50
+views:
+  - name: internal
+    zones: ["foo.bar"]
+    networks: [0]
+activity:
+  - resource_type: record
+    action: update
+    interval: 10ms
+`)
+)
+
+func newMockClient(t *testing.T, config *Config, apiKey string) *api.Client {
+	ts := httptest.NewServer(New(mockLogger, config))
+	t.Cleanup(ts.Close)
+
+	client := api.NewClient(&http.Client{Timeout: 5 * time.Second}, api.SetAPIKey(apiKey))
+	var err error
+	client.Endpoint, err = url.Parse(ts.URL + "/v1/")
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestSimulator(t *testing.T) {
+	config, err := ParseConfig(mockConfig)
+	require.NoError(t, err)
+	client := newMockClient(t, config, "mockAPIKey")
+
+	zones, _, err := client.Zones.List()
+	require.NoError(t, err)
+	require.Len(t, zones, 1)
+	require.Equal(t, "foo.bar", zones[0].Zone)
+	require.Empty(t, zones[0].Records)
+
+	zone, _, err := client.Zones.Get("foo.bar", true)
+	require.NoError(t, err)
+	require.Equal(t, 3600, zone.TTL)
+	require.Equal(t, map[string]string{"team": "dns"}, zone.Tags)
+	require.Len(t, zone.Records, 2)
+	require.Equal(t, []string{"1.2.3.4"}, zone.Records[0].ShortAns)
+
+	_, _, err = client.Zones.Get("missing.zone", true)
+	require.ErrorIs(t, err, api.ErrZoneMissing)
+
+	record, _, err := client.Records.Get("foo.bar", "test.foo.bar", "A")
+	require.NoError(t, err)
+	require.Equal(t, "sim-test.foo.bar-A", record.ID)
+	require.Len(t, record.Answers, 1)
+	require.Equal(t, []string{"1.2.3.4"}, record.Answers[0].Rdata)
+	require.Len(t, record.Filters, 1)
+	require.Equal(t, "up", record.Filters[0].Type)
+
+	_, _, err = client.Records.Get("foo.bar", "missing.foo.bar", "A")
+	require.ErrorIs(t, err, api.ErrRecordMissing)
+
+	views, _, err := client.View.List()
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+	require.Equal(t, "internal", views[0].Name)
+	require.Equal(t, []string{"foo.bar"}, views[0].Zones)
+
+	qps, _, err := client.Stats.GetRecordQPS("foo.bar", "test.foo.bar", "A")
+	require.NoError(t, err)
+	require.InDelta(t, 100, qps, 0.001)
+
+	qps, _, err = client.Stats.GetZoneQPS("foo.bar")
+	require.NoError(t, err)
+	require.InDelta(t, 150, qps, 0.001)
+
+	qps, _, err = client.Stats.GetQPS()
+	require.NoError(t, err)
+	require.InDelta(t, 150, qps, 0.001)
+
+	time.Sleep(20 * time.Millisecond)
+	activity, _, err := client.Activity.List(api.Param{Key: "start", Value: "0"})
+	require.NoError(t, err)
+	require.Len(t, activity, 1)
+	require.Equal(t, "record", activity[0].ResourceType)
+}
+
+func TestSimulatorFaults(t *testing.T) {
+	tests := map[string]struct {
+		faults     FaultConfig
+		apiKey     string
+		wantStatus int
+	}{
+		"unauthorized": {apiKey: "wrongAPIKey", wantStatus: http.StatusUnauthorized},
+		"rate_limited": {faults: FaultConfig{RateLimitProbability: 1}, apiKey: "mockAPIKey", wantStatus: http.StatusTooManyRequests},
+		"server_error": {faults: FaultConfig{ServerErrorProbability: 1, ServerErrorStatus: http.StatusServiceUnavailable}, apiKey: "mockAPIKey", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := ParseConfig(mockConfig)
+			require.NoError(t, err)
+			config.Faults = tc.faults
+			client := newMockClient(t, config, tc.apiKey)
+
+			_, _, err = client.Zones.List()
+			var apiErr *api.Error
+			require.ErrorAs(t, err, &apiErr)
+			require.Equal(t, tc.wantStatus, apiErr.Resp.StatusCode)
+		})
+	}
+}
+
+func TestQPSCurve(t *testing.T) {
+	curve := &QPSCurve{Base: 100, Amplitude: 50, Period: 4 * time.Second}
+
+	require.InDelta(t, 100, curve.Value(time.Unix(0, 0)), 0.001)
+	require.InDelta(t, 150, curve.Value(time.Unix(1, 0)), 0.001)
+	require.InDelta(t, 50, curve.Value(time.Unix(3, 0)), 0.001)
+
+	noisy := &QPSCurve{Base: 100, Noise: 0.1}
+	for range 100 {
+		require.InDelta(t, 100, noisy.Value(time.Now()), 10)
+	}
+}
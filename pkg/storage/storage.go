@@ -0,0 +1,197 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+// SchemaVersion is the version of the on-disk snapshot format. It must be
+// bumped whenever the layout of any snapshotted data changes in a way that
+// would prevent older snapshots from being decoded correctly.
+const SchemaVersion = 1
+
+// ErrSchemaMismatch is returned when a snapshot on disk was written with a
+// different schema version than the one supported by this build.
+var ErrSchemaMismatch = errors.New("snapshot schema version mismatch")
+
+// snapshot is the envelope that wraps all data written to disk.
+type snapshot struct {
+	SchemaVersion int             `json:"schema_version"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Store persists snapshots of worker caches to a directory on disk so that
+// they can be loaded on startup for warm restarts. It implements the
+// prometheus.Collector interface to expose the age of each snapshot.
+type Store struct {
+	dir    string
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	timestamps map[string]time.Time
+}
+
+// New creates a new Store that reads and writes snapshots in the provided
+// directory, creating it if needed.
+func New(logger *slog.Logger, dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	store := &Store{
+		dir:        dir,
+		logger:     logger.With("component", "storage"),
+		timestamps: make(map[string]time.Time),
+	}
+
+	// register store for metrics collection
+	metrics.Registry.MustRegister(store)
+
+	return store, nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// Save writes the provided data to disk as the snapshot with the given name.
+// The snapshot is written atomically, so a crash while saving will never leave
+// a partially written snapshot in place of the previous one.
+func (s *Store) Save(name string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot data: %w", err)
+	}
+
+	ts := time.Now().UTC()
+	buf, err := json.Marshal(snapshot{
+		SchemaVersion: SchemaVersion,
+		Timestamp:     ts,
+		Data:          raw,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := WriteFileAtomic(s.path(name), buf, 0o640); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.timestamps[name] = ts
+	s.mu.Unlock()
+	s.logger.Debug("Saved snapshot", "snapshot", name, "bytes", len(buf))
+
+	return nil
+}
+
+// Load reads the snapshot with the given name from disk and decodes it into
+// data, which must be a pointer. It returns the time at which the snapshot was
+// taken. If no snapshot exists, the returned error wraps os.ErrNotExist.
+func (s *Store) Load(name string, data any) (time.Time, error) {
+	buf, err := os.ReadFile(s.path(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(buf, &snap); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if snap.SchemaVersion != SchemaVersion {
+		return time.Time{}, fmt.Errorf("%w: found version %d, expected version %d", ErrSchemaMismatch, snap.SchemaVersion, SchemaVersion)
+	}
+
+	if err := json.Unmarshal(snap.Data, data); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode snapshot data: %w", err)
+	}
+
+	s.mu.Lock()
+	s.timestamps[name] = snap.Timestamp
+	s.mu.Unlock()
+
+	return snap.Timestamp, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (s *Store) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.MetricStorageSnapshotAgeDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (s *Store) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for name, ts := range s.timestamps {
+		ch <- prometheus.MustNewConstMetric(
+			metrics.MetricStorageSnapshotAgeDesc, prometheus.GaugeValue, now.Sub(ts).Seconds(), name,
+		)
+	}
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory as
+// path and then renames it into place, so that readers never observe a
+// partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	// best effort cleanup of the temp file if anything below fails. after a
+	// successful rename, the temp file no longer exists and this is a no-op.
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to rename temporary file into place: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 TJ Hoplock
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tjhop/ns1_exporter/pkg/metrics"
+)
+
+var (
+	mockLogger = promslog.New(&promslog.Config{})
+)
+
+type mockData struct {
+	Zones   []string
+	Records map[string]int
+}
+
+func TestSaveLoad(t *testing.T) {
+	store, err := New(mockLogger, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { metrics.Registry.Unregister(store) })
+
+	tests := map[string]struct {
+		data mockData
+	}{
+		"empty":     {data: mockData{}},
+		"some_data": {data: mockData{Zones: []string{"foo.bar", "keep.me"}, Records: map[string]int{"test.foo.bar": 2}}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Save(name, tc.data))
+
+			var got mockData
+			ts, err := store.Load(name, &got)
+			require.NoError(t, err)
+			require.False(t, ts.IsZero())
+			require.Equal(t, tc.data, got)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(mockLogger, dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { metrics.Registry.Unregister(store) })
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.json"), []byte(`{"schema_version": 0, "data": {}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "corrupt.json"), []byte(`{"schema_ver`), 0o600))
+
+	tests := map[string]struct {
+		name    string
+		checkFn func(t *testing.T, err error)
+	}{
+		"missing": {name: "missing", checkFn: func(t *testing.T, err error) { require.ErrorIs(t, err, os.ErrNotExist) }},
+		"schema":  {name: "old", checkFn: func(t *testing.T, err error) { require.ErrorIs(t, err, ErrSchemaMismatch) }},
+		"corrupt": {name: "corrupt", checkFn: func(t *testing.T, err error) { require.Error(t, err) }},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got mockData
+			_, err := store.Load(tc.name, &got)
+			tc.checkFn(t, err)
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.json")
+
+	require.NoError(t, WriteFileAtomic(path, []byte("first"), 0o600))
+	require.NoError(t, WriteFileAtomic(path, []byte("second"), 0o600))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(got))
+
+	// no temp files should be left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}